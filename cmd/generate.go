@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/icco/genidi/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	genOpts  tui.GenerateOptions
+	genForce bool
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate <file.mid>",
+	Short: "Generate a pattern from constraints and save it as a MIDI file",
+	Long: `Generate a pattern for every channel from a density, scale, note range and
+rhythm style, and save it as a MIDI file that can be opened in manual mode.
+
+The same options and seed always give the same pattern. The seed is printed so
+a pattern can be made again.
+
+Scales: ` + strings.Join(tui.ScaleNames(), ", ") + `
+Rhythm styles: ` + strings.Join(tui.RhythmStyleNames(), ", ") + `
+
+Example:
+  genidi generate bassline.mid --root D --scale dorian --low D2 --high D3 --style offbeat --seed 7
+`,
+	Args: cobra.ExactArgs(1),
+	Run:  runGenerate,
+}
+
+func init() {
+	generateCmd.Flags().IntVarP(&genOpts.Density, "density", "d", 50, "Percentage of steps that play")
+	generateCmd.Flags().StringVarP(&genOpts.Root, "root", "r", "C", "Root note of the scale")
+	generateCmd.Flags().StringVarP(&genOpts.Scale, "scale", "s", "major", "Scale the notes are chosen from")
+	generateCmd.Flags().StringVar(&genOpts.Low, "low", "C3", "Lowest note")
+	generateCmd.Flags().StringVar(&genOpts.High, "high", "C5", "Highest note")
+	generateCmd.Flags().StringVar(&genOpts.Style, "style", "straight", "Rhythm style")
+	generateCmd.Flags().Uint64Var(&genOpts.Seed, "seed", 0, "Seed for the random choices (0 picks one)")
+	generateCmd.Flags().IntVarP(&genOpts.BPM, "bpm", "b", 120, "Tempo")
+	generateCmd.Flags().BoolVarP(&genForce, "force", "f", false, "Overwrite the file if it exists")
+	rootCmd.AddCommand(generateCmd)
+}
+
+func runGenerate(_ *cobra.Command, args []string) {
+	path := args[0]
+	if _, err := os.Stat(path); err == nil && !genForce {
+		fmt.Fprintf(os.Stderr, "%s already exists (use --force to overwrite)\n", path)
+		os.Exit(1)
+	}
+
+	seed, err := tui.Generate(path, genOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating pattern: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s (seed %d)\n", path, seed)
+}
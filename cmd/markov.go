@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/icco/genidi/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	markovOpts  tui.MarkovOptions
+	markovForce bool
+)
+
+var markovCmd = &cobra.Command{
+	Use:   "markov <corpus-dir> <file.mid>",
+	Short: "Generate a pattern in the style of a directory of MIDI files",
+	Long: `Learn the notes and rhythms of every MIDI file under a directory with a
+Markov chain, then generate a pattern for every channel in the same style and
+save it as a MIDI file.
+
+The order is how many steps back each choice looks: higher orders follow the
+files more closely, lower orders wander further from them. The same corpus,
+order and seed always give the same pattern, and the seed is printed.
+
+Example:
+  genidi markov ~/midi/basslines new_bassline.mid --order 3 --seed 7
+`,
+	Args: cobra.ExactArgs(2),
+	Run:  runMarkov,
+}
+
+func init() {
+	markovCmd.Flags().IntVarP(&markovOpts.Order, "order", "o", 2, "Steps of context for each choice (1-4)")
+	markovCmd.Flags().Uint64Var(&markovOpts.Seed, "seed", 0, "Seed for the random choices (0 picks one)")
+	markovCmd.Flags().IntVarP(&markovOpts.BPM, "bpm", "b", 120, "Tempo")
+	markovCmd.Flags().BoolVarP(&markovForce, "force", "f", false, "Overwrite the file if it exists")
+	rootCmd.AddCommand(markovCmd)
+}
+
+func runMarkov(_ *cobra.Command, args []string) {
+	markovOpts.Corpus = args[0]
+	path := args[1]
+	if _, err := os.Stat(path); err == nil && !markovForce {
+		fmt.Fprintf(os.Stderr, "%s already exists (use --force to overwrite)\n", path)
+		os.Exit(1)
+	}
+
+	seed, err := tui.GenerateMarkov(path, markovOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating pattern: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s (seed %d)\n", path, seed)
+}
@@ -5,12 +5,18 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/icco/genidi/internal/arp"
 	"github.com/icco/genidi/internal/audio"
+	"github.com/icco/genidi/internal/piano"
+	"github.com/icco/genidi/internal/tempo"
 	"github.com/spf13/cobra"
 	"gitlab.com/gomidi/midi/v2/drivers"
 	"gitlab.com/gomidi/midi/v2/drivers/rtmididrv"
@@ -18,6 +24,11 @@ import (
 
 var (
 	deviceName string
+	arpMode    string
+	arpRate    string
+	arpOctaves int
+	arpGate    int
+	arpBPM     int
 )
 
 var virtualCmd = &cobra.Command{
@@ -28,19 +39,36 @@ var virtualCmd = &cobra.Command{
 The virtual device will show up as a MIDI output destination in other music software.
 Any MIDI notes received will be played through the system audio output using a built-in synthesizer.
 
+Held notes can be arpeggiated. The arpeggiator follows MIDI clock sent to the
+device, and runs from its own clock at --bpm when none arrives.
+
+Press tab to play the computer keyboard as a piano on channel 1, with the
+home row as the white keys.
+
 Example:
   genidi virtual --name "My Synth"
+  genidi virtual --arp up-down --arp-rate 1/16 --arp-octaves 2 --bpm 128
 `,
 	Run: runVirtual,
 }
 
 func init() {
 	virtualCmd.Flags().StringVarP(&deviceName, "name", "n", "Genidi Virtual Synth", "Name for the virtual MIDI device")
+	virtualCmd.Flags().StringVar(&arpMode, "arp", "off", "Arpeggiator mode: off, up, down, up-down, random or as-played")
+	virtualCmd.Flags().StringVar(&arpRate, "arp-rate", "1/16", "Arpeggiator rate: 1/4, 1/8, 1/8T, 1/16, 1/16T or 1/32")
+	virtualCmd.Flags().IntVar(&arpOctaves, "arp-octaves", 1, "Octaves the arpeggiator spans (1-4)")
+	virtualCmd.Flags().IntVar(&arpGate, "arp-gate", 50, "Arpeggiator gate, as a percentage of the rate")
+	virtualCmd.Flags().IntVar(&arpBPM, "bpm", 120, "Arpeggiator tempo when no MIDI clock is received")
 	rootCmd.AddCommand(virtualCmd)
 }
 
 func runVirtual(cmd *cobra.Command, args []string) {
-	m := newVirtualModel(deviceName)
+	settings, err := arpSettings()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	m := newVirtualModel(deviceName, settings)
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	m.program = p // Store reference so MIDI callback can send messages
 
@@ -60,6 +88,13 @@ func runVirtual(cmd *cobra.Command, args []string) {
 
 const maxMessageHistory = 20
 
+// clockTimeout is how long after the last MIDI clock pulse the arpeggiator
+// goes back to its own clock.
+const clockTimeout = 500 * time.Millisecond
+
+// pianoChannel is the channel the computer keyboard plays on.
+const pianoChannel = 0
+
 // virtualModel represents the TUI state for the virtual MIDI device
 type virtualModel struct {
 	deviceName     string
@@ -75,6 +110,19 @@ type virtualModel struct {
 	width          int
 	height         int
 	program        *tea.Program // Reference to send messages from MIDI callback
+
+	// Arpeggiator, shared by the MIDI callback, the clock and the TUI
+	arpMu        sync.Mutex
+	arp          arp.Settings
+	arps         map[uint8]*arp.Arpeggiator // Held notes by channel while the arpeggiator is on
+	arpVelocity  [16]uint8                  // Velocity of the last note held on each channel
+	lastClock    time.Time                  // When MIDI clock last arrived
+	stopArpClock chan struct{}
+	stopOnce     sync.Once
+
+	// Computer keyboard piano
+	piano   *piano.Keyboard
+	pianoOn bool // Whether keys play notes
 }
 
 type noteDisplay struct {
@@ -94,11 +142,15 @@ type midiEventMsg struct {
 	value      uint8 // for CC messages
 }
 
-func newVirtualModel(name string) *virtualModel {
+func newVirtualModel(name string, settings arp.Settings) *virtualModel {
 	return &virtualModel{
 		deviceName:     name,
 		activeNotes:    make(map[string]noteDisplay),
 		messageHistory: make([]string, 0, maxMessageHistory),
+		arp:            settings,
+		arps:           make(map[uint8]*arp.Arpeggiator),
+		stopArpClock:   make(chan struct{}),
+		piano:          piano.New(),
 	}
 }
 
@@ -158,7 +210,8 @@ func (m *virtualModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.driver = msg.driver
 		m.inPort = msg.inPort
 
-		// Start listening for MIDI messages
+		// Start the arpeggiator's own clock and listen for MIDI messages
+		go m.runArpClock(arpBPM)
 		return m, m.listenMIDI
 
 	case midiEventMsg:
@@ -166,10 +219,24 @@ func (m *virtualModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.messageCount++
 		return m, nil
 
+	case pianoReleaseMsg:
+		if m.piano.Release(msg.press) {
+			m.pianoNoteOff(msg.press.Note)
+		}
+		return m, nil
+
 	case tea.KeyMsg:
+		if m.pianoOn && msg.String() != "ctrl+c" {
+			return m, m.updatePiano(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c":
 			return m, m.cleanup
+		case "a":
+			m.cycleArpMode()
+		case "tab":
+			m.pianoOn = true
+			m.lastMessage = "Piano: " + m.piano.String()
 		}
 	}
 
@@ -188,6 +255,10 @@ func (m *virtualModel) listenMIDI() tea.Msg {
 		}
 
 		status := data[0]
+		if status == 0xF8 { // Timing Clock
+			m.clockPulse()
+			return
+		}
 		msgType := status & 0xF0
 		// Read the channel from the MIDI message (lower 4 bits of status byte)
 		channel := status & 0x0F
@@ -197,12 +268,12 @@ func (m *virtualModel) listenMIDI() tea.Msg {
 			if len(data) >= 3 {
 				note := data[1]
 				velocity := data[2]
-				// Play the note through synth
+				// Play the note through synth, or hold it for the arpeggiator
 				if m.synth != nil {
 					if velocity > 0 {
-						m.synth.NoteOn(channel, note, velocity)
+						m.noteOn(channel, note, velocity)
 					} else {
-						m.synth.NoteOff(channel, note)
+						m.noteOff(channel, note)
 					}
 				}
 				// Send message to update UI
@@ -219,7 +290,7 @@ func (m *virtualModel) listenMIDI() tea.Msg {
 			if len(data) >= 3 {
 				note := data[1]
 				if m.synth != nil {
-					m.synth.NoteOff(channel, note)
+					m.noteOff(channel, note)
 				}
 				// Send message to update UI
 				if m.program != nil {
@@ -256,7 +327,7 @@ func (m *virtualModel) listenMIDI() tea.Msg {
 				})
 			}
 		}
-	}, drivers.ListenConfig{})
+	}, drivers.ListenConfig{TimeCode: true}) // TimeCode lets MIDI clock through for the arpeggiator
 
 	if err != nil {
 		m.err = fmt.Errorf("failed to listen to MIDI port: %w", err)
@@ -316,6 +387,7 @@ func (m *virtualModel) handleMIDIEvent(msg midiEventMsg) {
 
 func (m *virtualModel) cleanup() tea.Msg {
 	var errs []error
+	m.stopOnce.Do(func() { close(m.stopArpClock) })
 	// Stop listener
 	if m.stopFunc != nil {
 		m.stopFunc()
@@ -390,6 +462,7 @@ func (m *virtualModel) View() string {
 	} else {
 		b.WriteString(subtitleStyle.Render("MIDI Port: ") + "Initializing...\n\n")
 	}
+	b.WriteString(subtitleStyle.Render("Arpeggiator: ") + m.arpStatus() + "\n\n")
 
 	// Status
 	b.WriteString(statusStyle.Render("● Listening for MIDI") + "\n\n")
@@ -432,18 +505,203 @@ func (m *virtualModel) View() string {
 		}
 	}
 
-	// Keyboard visualization
-	b.WriteString("\n" + renderKeyboard(m.activeNotes) + "\n")
+	// Keyboard visualization, following the piano's octave while it plays
+	lowest := 3
+	if m.pianoOn {
+		b.WriteString("\n" + subtitleStyle.Render("Piano: ") + m.piano.String() + "\n")
+		lowest = m.piano.Octave
+	}
+	b.WriteString("\n" + renderKeyboard(m.activeNotes, lowest) + "\n")
 
 	// Help
-	b.WriteString("\n" + helpStyle.Render("Ctrl+C: quit"))
+	if m.pianoOn {
+		b.WriteString("\n" + helpStyle.Render("a-' and w-p: notes • z/x: octave • c/v: velocity • tab/esc: stop playing • Ctrl+C: quit"))
+	} else {
+		b.WriteString("\n" + helpStyle.Render("a: arpeggiator mode • tab: play the computer keyboard • Ctrl+C: quit"))
+	}
 
 	return b.String()
 }
 
-func renderKeyboard(activeNotes map[string]noteDisplay) string {
-	// Simple piano keyboard visualization (2 octaves around middle C)
-	// Starting from C3 (48) to B4 (71) = 24 notes
+// arpSettings reads the arpeggiator flags.
+func arpSettings() (arp.Settings, error) {
+	mode, err := arp.ParseMode(arpMode)
+	if err != nil {
+		return arp.Settings{}, err
+	}
+	rate, err := arp.ParseRate(arpRate)
+	if err != nil {
+		return arp.Settings{}, err
+	}
+	if arpOctaves < arp.MinOctaves || arpOctaves > arp.MaxOctaves {
+		return arp.Settings{}, fmt.Errorf("arpeggiator octaves %d is not between %d and %d", arpOctaves, arp.MinOctaves, arp.MaxOctaves)
+	}
+	if arpGate < arp.MinGate || arpGate > arp.MaxGate {
+		return arp.Settings{}, fmt.Errorf("arpeggiator gate %d%% is not between %d%% and %d%%", arpGate, arp.MinGate, arp.MaxGate)
+	}
+	if arpBPM < tempo.MinBPM || arpBPM > tempo.MaxBPM {
+		return arp.Settings{}, fmt.Errorf("tempo %d BPM is not between %d and %d", arpBPM, tempo.MinBPM, tempo.MaxBPM)
+	}
+	return arp.Settings{Mode: mode, Rate: rate, Octaves: arpOctaves, Gate: arpGate}, nil
+}
+
+// noteOn plays a received note, or holds it for the arpeggiator when it is on.
+func (m *virtualModel) noteOn(channel, note, velocity uint8) {
+	m.arpMu.Lock()
+	defer m.arpMu.Unlock()
+	if m.arp.Mode == arp.Off {
+		m.synth.NoteOn(channel, note, velocity)
+		return
+	}
+	a, ok := m.arps[channel]
+	if !ok {
+		a = arp.New(m.arp, uint64(time.Now().UnixNano())) // #nosec G115 -- any seed will do
+		m.arps[channel] = a
+	}
+	a.NoteOn(note)
+	m.arpVelocity[channel&0x0F] = velocity
+}
+
+// noteOff releases a received note, from the arpeggiator if it holds it.
+func (m *virtualModel) noteOff(channel, note uint8) {
+	m.arpMu.Lock()
+	defer m.arpMu.Unlock()
+	if a, ok := m.arps[channel]; ok && slices.Contains(a.Held(), note) {
+		m.playArp(channel, a.NoteOff(note))
+		return
+	}
+	m.synth.NoteOff(channel, note)
+}
+
+// playArp sends arpeggiator notes to the synth. arpMu must be held.
+func (m *virtualModel) playArp(channel uint8, events []arp.Event) {
+	for _, ev := range events {
+		if ev.On {
+			m.synth.NoteOn(channel, ev.Note, m.arpVelocity[channel&0x0F])
+		} else {
+			m.synth.NoteOff(channel, ev.Note)
+		}
+	}
+}
+
+// pulseArps moves every channel's arpeggio on by one 24 PPQN clock pulse.
+func (m *virtualModel) pulseArps() {
+	m.arpMu.Lock()
+	defer m.arpMu.Unlock()
+	for channel, a := range m.arps {
+		m.playArp(channel, a.Pulse())
+	}
+}
+
+// clockPulse follows a MIDI clock pulse from the sender.
+func (m *virtualModel) clockPulse() {
+	m.arpMu.Lock()
+	m.lastClock = time.Now()
+	m.arpMu.Unlock()
+	m.pulseArps()
+}
+
+// externalClock reports whether MIDI clock is arriving.
+func (m *virtualModel) externalClock() bool {
+	m.arpMu.Lock()
+	defer m.arpMu.Unlock()
+	return time.Since(m.lastClock) < clockTimeout
+}
+
+// runArpClock pulses the arpeggiator at bpm until cleanup, except while
+// MIDI clock is arriving.
+func (m *virtualModel) runArpClock(bpm int) {
+	ticker := time.NewTicker(time.Minute / time.Duration(bpm*24))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopArpClock:
+			return
+		case <-ticker.C:
+			if !m.externalClock() {
+				m.pulseArps()
+			}
+		}
+	}
+}
+
+// cycleArpMode switches to the next arpeggiator mode. Held notes are
+// released, so the new mode starts from the next notes played.
+func (m *virtualModel) cycleArpMode() {
+	m.arpMu.Lock()
+	defer m.arpMu.Unlock()
+	if m.synth != nil {
+		for channel, a := range m.arps {
+			m.playArp(channel, a.Reset())
+		}
+	}
+	m.arps = make(map[uint8]*arp.Arpeggiator)
+	m.arp.Mode = (m.arp.Mode + 1) % arp.NumModes
+	m.lastMessage = "Arpeggiator: " + m.arp.String()
+}
+
+// arpStatus describes the arpeggiator and the clock it follows.
+func (m *virtualModel) arpStatus() string {
+	m.arpMu.Lock()
+	settings := m.arp
+	m.arpMu.Unlock()
+	if settings.Mode == arp.Off {
+		return "off"
+	}
+	if m.externalClock() {
+		return settings.String() + " • MIDI clock"
+	}
+	return fmt.Sprintf("%s • internal clock at %d BPM", settings, arpBPM)
+}
+
+// pianoReleaseMsg ends a note played on the computer keyboard.
+type pianoReleaseMsg struct {
+	press piano.Press
+}
+
+// updatePiano plays keys on the synth, through the arpeggiator when it is
+// on. Notes show on the keyboard and in the log like received ones.
+func (m *virtualModel) updatePiano(msg tea.KeyMsg) tea.Cmd {
+	key := msg.String()
+	if p, ok := m.piano.Play(key); ok {
+		if p.Start {
+			m.pianoNoteOn(p.Note, p.Velocity)
+		}
+		return tea.Tick(piano.Hold, func(time.Time) tea.Msg {
+			return pianoReleaseMsg{press: p}
+		})
+	}
+	switch {
+	case m.piano.Adjust(key):
+		m.lastMessage = "Piano: " + m.piano.String()
+	case key == "tab" || key == "esc":
+		m.pianoOn = false
+		for _, note := range m.piano.Reset() {
+			m.pianoNoteOff(note)
+		}
+	}
+	return nil
+}
+
+func (m *virtualModel) pianoNoteOn(note, velocity uint8) {
+	if m.synth != nil {
+		m.noteOn(pianoChannel, note, velocity)
+	}
+	m.handleMIDIEvent(midiEventMsg{msgType: "noteOn", channel: pianoChannel, note: note, velocity: velocity})
+	m.messageCount++
+}
+
+func (m *virtualModel) pianoNoteOff(note uint8) {
+	if m.synth != nil {
+		m.noteOff(pianoChannel, note)
+	}
+	m.handleMIDIEvent(midiEventMsg{msgType: "noteOff", channel: pianoChannel, note: note})
+	m.messageCount++
+}
+
+func renderKeyboard(activeNotes map[string]noteDisplay, lowest int) string {
+	// Simple piano keyboard visualization: 2 octaves from C of the lowest
+	// octave, e.g. C3 (48) to B4 (71) = 24 notes
 
 	activeSet := make(map[uint8]bool)
 	for _, nd := range activeNotes {
@@ -459,9 +717,10 @@ func renderKeyboard(activeNotes map[string]noteDisplay) string {
 	// Black keys: C# D# _ F# G# A#
 	var top, bottom strings.Builder
 
-	for octave := 3; octave <= 4; octave++ {
-		// octave is 3 or 4, so octave*12+12 is 48 or 60, well within uint8 range
-		baseNote := uint8(octave*12 + 12) // #nosec G115 -- octave is constrained to 3-4
+	lowest = min(max(lowest, piano.MinOctave), piano.MaxOctave-1)
+	for octave := lowest; octave <= lowest+1; octave++ {
+		// octave is at most 8, so octave*12+12 is at most 108, well within uint8 range
+		baseNote := uint8(octave*12 + 12) // #nosec G115 -- octave is constrained to 0-8
 
 		whiteKeys := []uint8{0, 2, 4, 5, 7, 9, 11}                        // C D E F G A B
 		blackKeys := []int{1, 3, -1, 6, 8, 10}                            // C# D# _ F# G# A#
@@ -0,0 +1,251 @@
+// Package arp provides an arpeggiator that plays held notes one at a time,
+// stepping on 24 PPQN MIDI clock pulses so it follows whatever clock drives it.
+package arp
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// Mode is the order held notes are played in.
+type Mode int
+
+const (
+	Off      Mode = iota // Notes play as held
+	Up                   // Lowest to highest
+	Down                 // Highest to lowest
+	UpDown               // Up then back down, without repeating the ends
+	Random               // Any held note each time
+	AsPlayed             // In the order the notes were pressed
+	NumModes
+)
+
+var modeNames = [NumModes]string{"off", "up", "down", "up-down", "random", "as played"}
+
+func (m Mode) String() string {
+	if m < 0 || m >= NumModes {
+		return "unknown"
+	}
+	return modeNames[m]
+}
+
+// ParseMode finds a mode by name.
+func ParseMode(name string) (Mode, error) {
+	for i, n := range modeNames {
+		if strings.EqualFold(n, name) || strings.EqualFold(strings.ReplaceAll(n, " ", "-"), name) {
+			return Mode(i), nil
+		}
+	}
+	return Off, fmt.Errorf("unknown arpeggiator mode %q: choose from %s", name, strings.Join(modeNames[:], ", "))
+}
+
+// Rate is the length of each arpeggiated note in 24 PPQN clock pulses.
+type Rate int
+
+// Rates lists the note lengths on offer, longest first.
+var Rates = []Rate{24, 12, 8, 6, 4, 3}
+
+var rateNames = map[Rate]string{24: "1/4", 12: "1/8", 8: "1/8T", 6: "1/16", 4: "1/16T", 3: "1/32"}
+
+func (r Rate) String() string {
+	if name, ok := rateNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d pulses", int(r))
+}
+
+// ParseRate finds a rate by name, such as "1/16" or "1/8T".
+func ParseRate(name string) (Rate, error) {
+	for _, r := range Rates {
+		if strings.EqualFold(rateNames[r], name) {
+			return r, nil
+		}
+	}
+	var names []string
+	for _, r := range Rates {
+		names = append(names, rateNames[r])
+	}
+	return 0, fmt.Errorf("unknown arpeggiator rate %q: choose from %s", name, strings.Join(names, ", "))
+}
+
+const (
+	MinOctaves = 1
+	MaxOctaves = 4
+	MinGate    = 5   // Shortest gate, as a percentage of the rate
+	MaxGate    = 100 // Each note lasts until the next
+	maxNote    = 127
+)
+
+// Settings choose how held notes are arpeggiated.
+type Settings struct {
+	Mode    Mode
+	Rate    Rate
+	Octaves int // Octaves the held notes are repeated over
+	Gate    int // Percentage of the rate each note sounds for
+}
+
+// DefaultSettings are 1/16 notes over one octave at half gate, switched off.
+func DefaultSettings() Settings {
+	return Settings{Mode: Off, Rate: 6, Octaves: MinOctaves, Gate: 50}
+}
+
+// Valid returns the settings with anything out of range replaced by its
+// default, for settings read from files.
+func (s Settings) Valid() Settings {
+	d := DefaultSettings()
+	if s.Mode < 0 || s.Mode >= NumModes {
+		s.Mode = d.Mode
+	}
+	if !slices.Contains(Rates, s.Rate) {
+		s.Rate = d.Rate
+	}
+	if s.Octaves < MinOctaves || s.Octaves > MaxOctaves {
+		s.Octaves = d.Octaves
+	}
+	if s.Gate < MinGate || s.Gate > MaxGate {
+		s.Gate = d.Gate
+	}
+	return s
+}
+
+func (s Settings) String() string {
+	if s.Mode == Off {
+		return "off"
+	}
+	return fmt.Sprintf("%s %s over %d octave(s), gate %d%%", s.Mode, s.Rate, s.Octaves, s.Gate)
+}
+
+// Cycle returns the notes one pass of the arpeggio plays, given the held
+// notes in the order they were pressed. In Random mode they are the notes to
+// choose from.
+func (s Settings) Cycle(held []uint8) []uint8 {
+	var base []uint8
+	for _, n := range held {
+		if !slices.Contains(base, n) {
+			base = append(base, n)
+		}
+	}
+	if s.Mode != AsPlayed {
+		slices.Sort(base)
+	}
+
+	var notes []uint8
+	for octave := range max(s.Octaves, 1) {
+		for _, n := range base {
+			if int(n)+12*octave <= maxNote {
+				notes = append(notes, n+uint8(12*octave)) //nolint:gosec // checked against maxNote above
+			}
+		}
+	}
+
+	switch s.Mode {
+	case Down:
+		slices.Reverse(notes)
+	case UpDown:
+		if len(notes) > 2 {
+			down := slices.Clone(notes[1 : len(notes)-1])
+			slices.Reverse(down)
+			notes = append(notes, down...)
+		}
+	}
+	return notes
+}
+
+// Note returns the ith note played over a cycle.
+func (s Settings) Note(cycle []uint8, i int, rng *rand.Rand) uint8 {
+	if s.Mode == Random {
+		return cycle[rng.IntN(len(cycle))]
+	}
+	return cycle[i%len(cycle)]
+}
+
+// Event is a note for the arpeggiator's output to start or stop.
+type Event struct {
+	Note uint8
+	On   bool
+}
+
+// Arpeggiator plays the notes held on one channel as they are pressed and
+// released, a note every Rate pulses. It is not safe for concurrent use.
+type Arpeggiator struct {
+	Settings
+	held     []uint8 // In the order they were pressed
+	rng      *rand.Rand
+	pulse    int // Pulses since the first note was pressed
+	played   int // Notes played since then
+	sounding int // Note sounding, or -1
+	offAt    int // Pulse the sounding note stops on
+}
+
+// New returns an arpeggiator with nothing held. The seed makes Random mode
+// repeatable.
+func New(settings Settings, seed uint64) *Arpeggiator {
+	return &Arpeggiator{
+		Settings: settings.Valid(),
+		rng:      rand.New(rand.NewPCG(seed, 0)), //nolint:gosec // musical randomness, not security
+		sounding: -1,
+	}
+}
+
+// NoteOn holds a note. The first note held starts the arpeggio on the next
+// pulse.
+func (a *Arpeggiator) NoteOn(note uint8) {
+	if slices.Contains(a.held, note) {
+		return
+	}
+	if len(a.held) == 0 {
+		a.pulse, a.played = 0, 0
+	}
+	a.held = append(a.held, note)
+}
+
+// NoteOff releases a held note. Once nothing is held, the sounding note stops.
+func (a *Arpeggiator) NoteOff(note uint8) []Event {
+	a.held = slices.DeleteFunc(a.held, func(n uint8) bool { return n == note })
+	if len(a.held) == 0 {
+		return a.Release()
+	}
+	return nil
+}
+
+// Release stops the sounding note, if any.
+func (a *Arpeggiator) Release() []Event {
+	if a.sounding < 0 {
+		return nil
+	}
+	ev := Event{Note: uint8(a.sounding)} //nolint:gosec // sounding is a note from Cycle
+	a.sounding = -1
+	return []Event{ev}
+}
+
+// Reset releases every held note and stops the sounding one.
+func (a *Arpeggiator) Reset() []Event {
+	a.held = nil
+	return a.Release()
+}
+
+// Held returns the notes held, in the order they were pressed.
+func (a *Arpeggiator) Held() []uint8 {
+	return slices.Clone(a.held)
+}
+
+// Pulse moves the arpeggio on by one clock pulse and returns the notes to
+// stop and start, stops first.
+func (a *Arpeggiator) Pulse() []Event {
+	var events []Event
+	if a.sounding >= 0 && a.pulse >= a.offAt {
+		events = append(events, a.Release()...)
+	}
+	if len(a.held) > 0 && a.pulse%int(a.Rate) == 0 {
+		events = append(events, a.Release()...)
+		note := a.Note(a.Cycle(a.held), a.played, a.rng)
+		a.played++
+		a.sounding = int(note)
+		a.offAt = a.pulse + max(int(a.Rate)*a.Gate/MaxGate, 1)
+		events = append(events, Event{Note: note, On: true})
+	}
+	a.pulse++
+	return events
+}
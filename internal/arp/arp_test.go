@@ -0,0 +1,109 @@
+package arp
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestCycle(t *testing.T) {
+	held := []uint8{64, 60, 67} // E, C, G pressed in that order
+	for _, tt := range []struct {
+		settings Settings
+		want     []uint8
+	}{
+		{Settings{Mode: Up, Octaves: 1}, []uint8{60, 64, 67}},
+		{Settings{Mode: Down, Octaves: 1}, []uint8{67, 64, 60}},
+		{Settings{Mode: UpDown, Octaves: 1}, []uint8{60, 64, 67, 64}},
+		{Settings{Mode: AsPlayed, Octaves: 1}, []uint8{64, 60, 67}},
+		{Settings{Mode: Up, Octaves: 2}, []uint8{60, 64, 67, 72, 76, 79}},
+		{Settings{Mode: AsPlayed, Octaves: 2}, []uint8{64, 60, 67, 76, 72, 79}},
+	} {
+		if got := tt.settings.Cycle(held); !slices.Equal(got, tt.want) {
+			t.Errorf("Expected %s over %d octave(s) to play %v, got %v", tt.settings.Mode, tt.settings.Octaves, tt.want, got)
+		}
+	}
+
+	// Octaves above the MIDI range are left out
+	if got := (Settings{Mode: Up, Octaves: 3}).Cycle([]uint8{110}); !slices.Equal(got, []uint8{110, 122}) {
+		t.Errorf("Expected notes past G9 to be dropped, got %v", got)
+	}
+}
+
+func TestRandomStaysInCycle(t *testing.T) {
+	s := Settings{Mode: Random, Octaves: 2}
+	cycle := s.Cycle([]uint8{60, 63})
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range 50 {
+		if note := s.Note(cycle, i, rng); !slices.Contains(cycle, note) {
+			t.Fatalf("Expected a note from %v, got %d", cycle, note)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, name := range []string{"up-down", "as played", "as-played", "RANDOM"} {
+		if _, err := ParseMode(name); err != nil {
+			t.Errorf("Expected %q to be a mode: %v", name, err)
+		}
+	}
+	if _, err := ParseMode("sideways"); err == nil {
+		t.Error("Expected an unknown mode to be refused")
+	}
+	if r, err := ParseRate("1/8t"); err != nil || r != 8 {
+		t.Errorf("Expected 1/8T to be 8 pulses, got %d (%v)", r, err)
+	}
+	if got := (Settings{Rate: 5, Octaves: 9, Gate: 0}).Valid(); got != DefaultSettings() {
+		t.Errorf("Expected out of range settings to fall back to the defaults, got %+v", got)
+	}
+}
+
+func TestArpeggiatorPulses(t *testing.T) {
+	a := New(Settings{Mode: Up, Rate: 6, Octaves: 1, Gate: 50}, 1)
+	a.NoteOn(67)
+	a.NoteOn(60)
+
+	var got []Event
+	var at []int
+	for pulse := range 13 {
+		for _, ev := range a.Pulse() {
+			got = append(got, ev)
+			at = append(at, pulse)
+		}
+	}
+	want := []Event{{60, true}, {60, false}, {67, true}, {67, false}, {60, true}}
+	if !slices.Equal(got, want) || !slices.Equal(at, []int{0, 3, 6, 9, 12}) {
+		t.Errorf("Expected %v at pulses 0, 3, 6, 9, 12, got %v at %v", want, got, at)
+	}
+
+	// Letting go of one note keeps the other going; letting go of both stops it
+	if evs := a.NoteOff(60); len(evs) != 0 {
+		t.Errorf("Expected the arpeggio to carry on, got %v", evs)
+	}
+	if evs := a.NoteOff(67); !slices.Equal(evs, []Event{{60, false}}) {
+		t.Errorf("Expected the sounding note to stop, got %v", evs)
+	}
+	for range 12 {
+		if evs := a.Pulse(); len(evs) != 0 {
+			t.Fatalf("Expected silence with nothing held, got %v", evs)
+		}
+	}
+
+	// Holding again restarts the arpeggio straight away
+	a.NoteOn(62)
+	if evs := a.Pulse(); !slices.Equal(evs, []Event{{62, true}}) {
+		t.Errorf("Expected the new note on the next pulse, got %v", evs)
+	}
+}
+
+func TestFullGateStopsBeforeNextNote(t *testing.T) {
+	a := New(Settings{Mode: Up, Rate: 3, Octaves: 1, Gate: MaxGate}, 1)
+	a.NoteOn(60)
+	a.NoteOn(62)
+	a.Pulse()
+	a.Pulse()
+	a.Pulse()
+	if evs := a.Pulse(); !slices.Equal(evs, []Event{{60, false}, {62, true}}) {
+		t.Errorf("Expected the note off before the next note on, got %v", evs)
+	}
+}
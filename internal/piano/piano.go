@@ -0,0 +1,120 @@
+// Package piano plays the computer keyboard as a piano: the home row holds the
+// white keys and the row above it the black keys, with keys to shift the
+// octave and change the velocity.
+package piano
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Hold is how long a note sounds after its key is pressed. Terminals report
+// key presses but not releases, so a key held down keeps its note sounding
+// through key repeat instead.
+const Hold = 300 * time.Millisecond
+
+const (
+	MinOctave       = 0
+	MaxOctave       = 8
+	DefaultOctave   = 3 // C3 on the A key
+	MinVelocity     = 1
+	MaxVelocity     = 127
+	DefaultVelocity = 100
+	velocityStep    = 16
+)
+
+// layout maps keys to semitones above the C on the A key, from C to the F
+// an octave and a half up.
+var layout = map[string]uint8{
+	"a": 0, "w": 1, "s": 2, "e": 3, "d": 4, "f": 5, "t": 6, "g": 7, "y": 8, "h": 9,
+	"u": 10, "j": 11, "k": 12, "o": 13, "l": 14, "p": 15, ";": 16, "'": 17,
+}
+
+// Keyboard is the computer keyboard played as a piano. It keeps track of the
+// notes sounding, so a key pressed again while its note sounds holds it
+// longer rather than playing it again.
+type Keyboard struct {
+	Octave   int
+	Velocity uint8
+	held     map[uint8]int // Sounding notes, by the press that last held each
+	presses  int
+}
+
+// New returns a keyboard in the default octave and velocity.
+func New() *Keyboard {
+	return &Keyboard{Octave: DefaultOctave, Velocity: DefaultVelocity, held: make(map[uint8]int)}
+}
+
+// Press is a piano key pressed. It lasts until Release, which should follow
+// Hold after it.
+type Press struct {
+	Note     uint8
+	Velocity uint8
+	Start    bool // Whether the note starts, rather than sounding for longer
+	id       int
+}
+
+// Play presses the key for a note. It reports false for keys that are not
+// piano keys.
+func (k *Keyboard) Play(key string) (Press, bool) {
+	offset, ok := layout[key]
+	if !ok {
+		return Press{}, false
+	}
+	k.presses++
+	note := uint8((k.Octave+1)*12) + offset //nolint:gosec // Octave is bounded by MaxOctave
+	_, sounding := k.held[note]
+	k.held[note] = k.presses
+	return Press{Note: note, Velocity: k.Velocity, Start: !sounding, id: k.presses}, true
+}
+
+// Release ends a press. It reports whether the note stops, which it does
+// unless its key was pressed again since.
+func (k *Keyboard) Release(p Press) bool {
+	if id, ok := k.held[p.Note]; !ok || id != p.id {
+		return false
+	}
+	delete(k.held, p.Note)
+	return true
+}
+
+// Reset stops every sounding note, returning them so they can be released.
+func (k *Keyboard) Reset() []uint8 {
+	notes := k.Held()
+	clear(k.held)
+	return notes
+}
+
+// Held returns the notes sounding, lowest first.
+func (k *Keyboard) Held() []uint8 {
+	notes := make([]uint8, 0, len(k.held))
+	for note := range k.held {
+		notes = append(notes, note)
+	}
+	slices.Sort(notes)
+	return notes
+}
+
+// Adjust handles the octave keys, z and x, and the velocity keys, c and v.
+// It reports false for any other key.
+func (k *Keyboard) Adjust(key string) bool {
+	switch key {
+	case "z":
+		k.Octave = max(k.Octave-1, MinOctave)
+	case "x":
+		k.Octave = min(k.Octave+1, MaxOctave)
+	case "c":
+		k.Velocity = uint8(max(int(k.Velocity)-velocityStep, MinVelocity)) //nolint:gosec // Bounded by MinVelocity
+	case "v":
+		k.Velocity = uint8(min(int(k.Velocity)+velocityStep, MaxVelocity)) //nolint:gosec // Bounded by MaxVelocity
+	default:
+		return false
+	}
+	return true
+}
+
+// String describes the octave and velocity, e.g. "octave 3 • velocity 100".
+func (k *Keyboard) String() string {
+	return fmt.Sprintf("octave %d • velocity %d", k.Octave, k.Velocity)
+}
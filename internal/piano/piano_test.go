@@ -0,0 +1,77 @@
+package piano
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlay(t *testing.T) {
+	k := New()
+	for _, tt := range []struct {
+		key  string
+		want uint8
+	}{
+		{"a", 48}, {"w", 49}, {"j", 59}, {"k", 60}, {"'", 65},
+	} {
+		if p, ok := k.Play(tt.key); !ok || p.Note != tt.want || p.Velocity != DefaultVelocity {
+			t.Errorf("Expected %q to play %d, got %+v", tt.key, tt.want, p)
+		}
+	}
+	if _, ok := k.Play("q"); ok {
+		t.Error("Expected q not to be a piano key")
+	}
+
+	// Up an octave and softer
+	for _, key := range []string{"x", "c", "c"} {
+		if !k.Adjust(key) {
+			t.Fatalf("Expected %q to adjust the keyboard", key)
+		}
+	}
+	if p, _ := k.Play("a"); p.Note != 60 || p.Velocity != DefaultVelocity-2*velocityStep {
+		t.Errorf("Expected a softer C4, got %+v", p)
+	}
+
+	// The octave and velocity stop at the ends of their ranges
+	for range 20 {
+		k.Adjust("x")
+		k.Adjust("v")
+	}
+	if k.Octave != MaxOctave || k.Velocity != MaxVelocity {
+		t.Errorf("Expected the top octave at full velocity, got %s", k)
+	}
+	if p, _ := k.Play("'"); p.Note > 127 {
+		t.Errorf("Expected the top key to stay in the MIDI range, got %d", p.Note)
+	}
+}
+
+func TestKeyRepeatHoldsNote(t *testing.T) {
+	k := New()
+	first, _ := k.Play("d")
+	repeat, _ := k.Play("d")
+	if !first.Start || repeat.Start {
+		t.Fatalf("Expected only the first press to start the note, got %+v then %+v", first, repeat)
+	}
+
+	// The first press ending leaves the note to the repeat
+	if k.Release(first) {
+		t.Error("Expected the note to keep sounding for the repeat")
+	}
+	if !k.Release(repeat) {
+		t.Error("Expected the note to stop with the last press")
+	}
+	if again, _ := k.Play("d"); !again.Start {
+		t.Error("Expected the note to start again once stopped")
+	}
+}
+
+func TestReset(t *testing.T) {
+	k := New()
+	g, _ := k.Play("g")
+	k.Play("a")
+	if got := k.Reset(); !slices.Equal(got, []uint8{48, 55}) {
+		t.Errorf("Expected C3 and G3 stopped, got %v", got)
+	}
+	if k.Release(g) {
+		t.Error("Expected a release after a reset to stop nothing")
+	}
+}
@@ -0,0 +1,8 @@
+// Package tempo holds the tempo range shared by the sequencer, the pattern
+// generators and the virtual synth's arpeggiator clock.
+package tempo
+
+const (
+	MinBPM = 20  // Slowest tempo that can be set or followed
+	MaxBPM = 300 // Fastest tempo that can be set or followed
+)
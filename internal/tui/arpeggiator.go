@@ -0,0 +1,153 @@
+package tui
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/icco/genidi/internal/arp"
+)
+
+// arpField is the setting highlighted in the arpeggiator overlay.
+type arpField int
+
+const (
+	arpMode arpField = iota
+	arpRate
+	arpOctaves
+	arpGate
+	numArpFields
+)
+
+const arpGateIncrement = 5
+
+// arpEditor is a channel's arpeggiator being set up in the overlay. It only
+// replaces the channel's settings on enter.
+type arpEditor struct {
+	arp.Settings
+	field arpField
+}
+
+// arp returns a channel's arpeggiator settings. Channels that never had one
+// set have it switched off.
+func (s *sequencerModel) arp(ch int) arp.Settings {
+	return s.arps[ch].Valid()
+}
+
+// arpEvents plays a step's notes one at a time, a note every arpeggiator rate
+// for as long as the step holds, so ties and gates set how long a chord is
+// arpeggiated. A note still sounding when the step ends is cut short there.
+func (s *sequencerModel) arpEvents(ch, step int, a arp.Settings, start, length uint32, trig int, rule trigRule) []noteEvent {
+	rate := uint32(a.Rate) * clockTicks //nolint:gosec // rates are small positive pulse counts
+	cycle := a.Cycle(s.stepNotes(ch, step))
+	// Random mode is seeded per step, so playback and export agree
+	rng := rand.New(rand.NewPCG(s.seed, uint64(trigID(ch, step)))) //nolint:gosec // musical randomness, not security
+	channel := uint8(ch)                                           //nolint:gosec // ch is bounded by numChannels constant
+
+	var events []noteEvent
+	for i, at := 0, start; at < start+length; i, at = i+1, at+rate {
+		note := a.Note(cycle, i, rng)
+		end := min(at+gateTicks(a.Gate, rate), start+length)
+		events = append(events,
+			noteEvent{tick: at, channel: channel, note: note, velocity: s.velocity(ch, step), trig: trig, rule: rule},
+			noteEvent{tick: end, channel: channel, note: note, trig: trig, rule: rule},
+		)
+	}
+	return events
+}
+
+// arpLabel describes the cursor channel's arpeggiator for the status line.
+func (s *sequencerModel) arpLabel() string {
+	return fmt.Sprintf("Arp Ch %d: %s", s.cursorY+1, s.arp(s.cursorY))
+}
+
+// openArp starts the arpeggiator overlay on the cursor's channel. A channel
+// without one starts out arpeggiating upwards.
+func (s *sequencerModel) openArp() {
+	a := s.arp(s.cursorY)
+	if a.Mode == arp.Off {
+		a.Mode = arp.Up
+	}
+	s.arpEdit = &arpEditor{Settings: a}
+}
+
+// change moves the highlighted setting by delta.
+func (e *arpEditor) change(delta int) {
+	switch e.field {
+	case arpMode:
+		e.Mode = (e.Mode + arp.NumModes + arp.Mode(delta)) % arp.NumModes
+	case arpRate:
+		// Rates run from longest to shortest, so up is faster
+		i := slices.Index(arp.Rates, e.Rate)
+		e.Rate = arp.Rates[min(max(i+delta, 0), len(arp.Rates)-1)]
+	case arpOctaves:
+		e.Octaves = min(max(e.Octaves+delta, arp.MinOctaves), arp.MaxOctaves)
+	case arpGate:
+		e.Gate = min(max(e.Gate+delta*arpGateIncrement, arp.MinGate), arp.MaxGate)
+	}
+}
+
+// updateArp handles keys in the arpeggiator overlay.
+func (s *sequencerModel) updateArp(msg tea.KeyMsg) {
+	e := s.arpEdit
+	switch msg.String() {
+	case keyLeft, "h":
+		e.field = (e.field + numArpFields - 1) % numArpFields
+	case keyRight, "l", "tab":
+		e.field = (e.field + 1) % numArpFields
+	case keyUp, "k", "+", "=":
+		e.change(1)
+	case keyDown, "j", "-", "_":
+		e.change(-1)
+	case "enter":
+		s.arps[s.cursorY] = e.Settings
+		s.arpEdit = nil
+		s.message = s.arpLabel()
+	case "esc", "q", "U":
+		s.arpEdit = nil
+	}
+}
+
+func (m model) viewArp() string {
+	s := m.sequencer
+	e := s.arpEdit
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Arpeggiator: Channel %d", s.cursorY+1)) + "\n\n")
+
+	values := [numArpFields]string{
+		"Mode: " + e.Mode.String(),
+		"Rate: " + e.Rate.String(),
+		fmt.Sprintf("Octaves: %d", e.Octaves),
+		fmt.Sprintf("Gate: %d%%", e.Gate),
+	}
+	for field, value := range values {
+		if arpField(field) == e.field {
+			b.WriteString(selectedStyle.Render("> "+value) + "   ")
+		} else {
+			b.WriteString("  " + value + "   ")
+		}
+	}
+	b.WriteString("\n\n")
+
+	// Show what the step under the cursor would play
+	if e.Mode != arp.Off {
+		var names []string
+		for _, note := range e.Cycle(s.stepNotes(s.cursorY, s.cursorX)) {
+			names = append(names, midiNoteToName(int(note)))
+		}
+		if e.Mode == arp.Random {
+			fmt.Fprintf(&b, "Step %X picks from: %s\n", s.cursorX, strings.Join(names, " "))
+		} else {
+			fmt.Fprintf(&b, "Step %X plays: %s\n", s.cursorX, strings.Join(names, " "))
+		}
+	}
+	b.WriteString("Each step's notes are arpeggiated for as long as the step is held; tie steps for longer runs.\n")
+
+	b.WriteString("\n" + helpStyle.Render("←/→: choose setting • ↑/↓: change • enter: keep • esc: cancel"))
+
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/icco/genidi/internal/arp"
+)
+
+func TestArpeggiatedStep(t *testing.T) {
+	s := &sequencerModel{bpm: 120}
+	s.resetBank()
+	s.resetMix()
+
+	// A C major triad held over two steps plays 1/16 notes up the chord
+	for step := range 2 {
+		s.steps[1][step] = true
+		s.notes[1][step] = 60
+		s.chords[1][step] = []int{4, 7}
+	}
+	s.ties[1][0] = true
+	s.arps[1] = arp.Settings{Mode: arp.Up, Rate: 3, Octaves: 1, Gate: 50}
+
+	var ons []uint8
+	var at []uint32
+	for _, ev := range s.channelEvents(1) {
+		if ev.velocity > 0 {
+			ons = append(ons, ev.note)
+			at = append(at, ev.tick)
+		}
+	}
+	if want := []uint8{60, 64, 67, 60}; !slices.Equal(ons, want) {
+		t.Errorf("Expected %v, got %v", want, ons)
+	}
+	if want := []uint32{0, 120, 240, 360}; !slices.Equal(at, want) {
+		t.Errorf("Expected notes at ticks %v, got %v", want, at)
+	}
+
+	// Switched off, the chord plays as one long note again
+	s.arps[1] = arp.Settings{}
+	if got := len(s.channelEvents(1)); got != 6 {
+		t.Errorf("Expected 3 notes on and off without the arpeggiator, got %d events", got)
+	}
+}
+
+func TestArpSettingsRoundTrip(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "arp.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.steps[3][0] = true
+	s.notes[3][0] = 48
+	want := arp.Settings{Mode: arp.UpDown, Rate: 8, Octaves: 2, Gate: 80}
+	s.arps[3] = want
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if got := s2.arp(3); got != want {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := s2.arp(0); got.Mode != arp.Off {
+		t.Errorf("Expected channel 1 to stay unarpeggiated, got %v", got)
+	}
+}
+
+func TestArpKeys(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "arp.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	m = pressKeys(m, "U", "up", "esc")
+	if m.sequencer.arpEdit != nil || m.sequencer.arp(0).Mode != arp.Off {
+		t.Fatal("Expected esc to leave the channel unarpeggiated")
+	}
+
+	// Up then down a mode, and one rate faster
+	m = pressKeys(m, "U", "up", "down", "right", "up", "enter")
+	if got := m.sequencer.arp(0); got.Mode != arp.Up || got.Rate != 4 {
+		t.Errorf("Expected up at 1/16T, got %v", got)
+	}
+
+	m = pressKeys(m, "u")
+	if got := m.sequencer.arp(0); got.Mode != arp.Off {
+		t.Errorf("Expected undo to switch the arpeggiator off, got %v", got)
+	}
+}
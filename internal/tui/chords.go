@@ -0,0 +1,189 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// chordType is a named set of intervals stacked above a step's root note.
+type chordType struct {
+	name      string
+	intervals []int
+}
+
+var chordTypes = []chordType{
+	{"maj", []int{4, 7}},
+	{"min", []int{3, 7}},
+	{"dim", []int{3, 6}},
+	{"aug", []int{4, 8}},
+	{"sus2", []int{2, 7}},
+	{"sus4", []int{5, 7}},
+	{"5", []int{7}},
+	{"maj7", []int{4, 7, 11}},
+	{"min7", []int{3, 7, 10}},
+	{"7", []int{4, 7, 10}},
+	{"dim7", []int{3, 6, 9}},
+	{"add9", []int{4, 7, 14}},
+}
+
+// intervalNames labels the intervals offered by the add-interval picker,
+// indexed by semitones minus one.
+var intervalNames = []string{
+	"minor 2nd", "major 2nd", "minor 3rd", "major 3rd", "perfect 4th", "tritone",
+	"perfect 5th", "minor 6th", "major 6th", "minor 7th", "major 7th", "octave",
+}
+
+// pickerKind identifies which list overlay is open in the sequencer.
+type pickerKind int
+
+const (
+	pickerNone pickerKind = iota
+	pickerChord
+	pickerInterval
+)
+
+// stepNotes returns every note a step plays: its root followed by any chord
+// notes, dropping those pushed past the top of the MIDI range.
+func (s *sequencerModel) stepNotes(ch, step int) []uint8 {
+	root := s.notes[ch][step]
+	notes := []uint8{uint8(root)} //nolint:gosec // root is bounded by the MIDI note range
+	for _, interval := range s.chords[ch][step] {
+		if n := root + interval; n <= maxMIDINote {
+			notes = append(notes, uint8(n)) //nolint:gosec // n is bounded by maxMIDINote
+		}
+	}
+	return notes
+}
+
+// sameChord reports whether two steps on a channel play the same notes.
+func (s *sequencerModel) sameChord(ch, a, b int) bool {
+	return s.notes[ch][a] == s.notes[ch][b] && slices.Equal(s.chords[ch][a], s.chords[ch][b])
+}
+
+// addChordNote adds an absolute note to a step (and any steps tied from it),
+// re-rooting the chord if the note is below the current root.
+func (s *sequencerModel) addChordNote(ch, step, note int) {
+	last := s.chainEnd(ch, step)
+	for i := step; i <= last; i++ {
+		root := s.notes[ch][i]
+		notes := []int{root}
+		for _, interval := range s.chords[ch][i] {
+			notes = append(notes, root+interval)
+		}
+		if slices.Contains(notes, note) {
+			continue
+		}
+		notes = append(notes, note)
+		slices.Sort(notes)
+
+		// Always allocate a fresh slice so copies of the model never share chords
+		intervals := make([]int, 0, len(notes)-1)
+		for _, n := range notes[1:] {
+			intervals = append(intervals, n-notes[0])
+		}
+		s.notes[ch][i] = notes[0]
+		s.chords[ch][i] = intervals
+	}
+}
+
+// chordName describes a step's chord, e.g. "C4 maj" or "C4 +3 +10".
+func (s *sequencerModel) chordName(ch, step int) string {
+	root := midiNoteToName(s.notes[ch][step])
+	intervals := s.chords[ch][step]
+	if len(intervals) == 0 {
+		return root
+	}
+	for _, ct := range chordTypes {
+		if slices.Equal(ct.intervals, intervals) {
+			return root + " " + ct.name
+		}
+	}
+	parts := []string{root}
+	for _, interval := range intervals {
+		parts = append(parts, fmt.Sprintf("+%d", interval))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *sequencerModel) openPicker(kind pickerKind) {
+	s.picker = kind
+	s.pickerCursor = 0
+}
+
+// updatePicker handles keys while a chord or interval list is open.
+func (s *sequencerModel) updatePicker(msg tea.KeyMsg) {
+	size := len(chordTypes)
+	if s.picker == pickerInterval {
+		size = len(intervalNames)
+	}
+
+	switch msg.String() {
+	case keyUp, "k":
+		if s.pickerCursor > 0 {
+			s.pickerCursor--
+		}
+	case keyDown, "j":
+		if s.pickerCursor < size-1 {
+			s.pickerCursor++
+		}
+	case "enter":
+		ch, step := s.cursorY, s.cursorX
+		s.steps[ch][step] = true
+		if s.picker == pickerChord {
+			ct := chordTypes[s.pickerCursor]
+			last := s.chainEnd(ch, step)
+			for i := step; i <= last; i++ {
+				s.chords[ch][i] = slices.Clone(ct.intervals)
+			}
+			s.message = fmt.Sprintf("Chord: %s", s.chordName(ch, step))
+		} else {
+			s.addChordNote(ch, step, s.notes[ch][step]+s.pickerCursor+1)
+			s.message = fmt.Sprintf("Added %s: %s", intervalNames[s.pickerCursor], s.chordName(ch, step))
+		}
+		s.picker = pickerNone
+	case "esc", "q":
+		s.picker = pickerNone
+	}
+}
+
+func (m model) viewPicker() string {
+	s := m.sequencer
+
+	var b strings.Builder
+
+	var items []string
+	if s.picker == pickerChord {
+		b.WriteString(titleStyle.Render("Select Chord Type") + "\n\n")
+		root := s.notes[s.cursorY][s.cursorX]
+		for _, ct := range chordTypes {
+			names := []string{midiNoteToName(root)}
+			for _, interval := range ct.intervals {
+				if root+interval <= maxMIDINote {
+					names = append(names, midiNoteToName(root+interval))
+				}
+			}
+			items = append(items, fmt.Sprintf("%-5s %s", ct.name, strings.Join(names, " ")))
+		}
+	} else {
+		b.WriteString(titleStyle.Render("Add Interval") + "\n\n")
+		for i, name := range intervalNames {
+			items = append(items, fmt.Sprintf("%-12s +%d", name, i+1))
+		}
+	}
+
+	fmt.Fprintf(&b, "Step %X on Ch %d: %s\n\n", s.cursorX, s.cursorY+1, s.chordName(s.cursorY, s.cursorX))
+	for i, item := range items {
+		if i == s.pickerCursor {
+			b.WriteString(selectedStyle.Render("> "+item) + "\n")
+		} else {
+			b.WriteString("  " + item + "\n")
+		}
+	}
+
+	b.WriteString("\n" + helpStyle.Render("↑/k: up • ↓/j: down • enter: apply • q/esc: cancel"))
+
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMIDIChordRoundTrip(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "chords.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	// C minor triad on a pad track, held across two steps
+	s.steps[2][0] = true
+	s.notes[2][0] = 60
+	s.chords[2][0] = []int{3, 7}
+	s.ties[2][0] = true
+	s.steps[2][1] = true
+	s.notes[2][1] = 60
+	s.chords[2][1] = []int{3, 7}
+
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+
+	if got, want := s2.stepNotes(2, 0), []uint8{60, 63, 67}; !slices.Equal(got, want) {
+		t.Errorf("Expected step 0 to play %v, got %v", want, got)
+	}
+	if !s2.tiedToNext(2, 0) {
+		t.Error("Expected the chord on step 0 to tie into step 1")
+	}
+	if got := s2.chordName(2, 0); got != "C4 min" {
+		t.Errorf("Expected chord name \"C4 min\", got %q", got)
+	}
+}
+
+func TestAddChordNoteReroots(t *testing.T) {
+	s := &sequencerModel{}
+	s.steps[0][0] = true
+	s.notes[0][0] = 64
+	s.chords[0][0] = []int{3}
+
+	// Adding a note below the root makes it the new root
+	s.addChordNote(0, 0, 60)
+
+	if got := s.notes[0][0]; got != 60 {
+		t.Errorf("Expected root 60, got %d", got)
+	}
+	if got, want := s.chords[0][0], []int{4, 7}; !slices.Equal(got, want) {
+		t.Errorf("Expected intervals %v, got %v", want, got)
+	}
+
+	// Adding a note that is already in the chord is a no-op
+	s.addChordNote(0, 0, 67)
+	if got := len(s.chords[0][0]); got != 2 {
+		t.Errorf("Expected 2 intervals after adding a duplicate, got %d", got)
+	}
+}
@@ -0,0 +1,251 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// selectionColor shades the visually selected steps in the grid.
+var selectionColor = lipgloss.Color("#3A2D6F")
+
+// cell is everything stored for one step of one channel.
+type cell struct {
+	on       bool
+	note     int
+	velocity int
+	gate     int
+	tie      bool
+	chord    []int
+	nudge    int
+	chance   int
+	cond     trigCondition
+	ratchet  int
+	lanes    [numLanes]laneStep // The step of each CC lane
+}
+
+// clipboard is a rectangle of cells, rows by channel and columns by step. It
+// outlives the file it was copied from, so it can be pasted into any file
+// opened in the same session.
+type clipboard [][]cell
+
+// selection returns the selected rectangle, from the anchor to the cursor
+// inclusive. Without a selection it is just the cursor's step.
+func (s *sequencerModel) selection() (x0, y0, x1, y1 int) {
+	if !s.selecting {
+		return s.cursorX, s.cursorY, s.cursorX, s.cursorY
+	}
+	return min(s.anchorX, s.cursorX), min(s.anchorY, s.cursorY),
+		max(s.anchorX, s.cursorX), max(s.anchorY, s.cursorY)
+}
+
+// inSelection reports whether a step is inside the visual selection.
+func (s *sequencerModel) inSelection(ch, step int) bool {
+	if !s.selecting {
+		return false
+	}
+	x0, y0, x1, y1 := s.selection()
+	return step >= x0 && step <= x1 && ch >= y0 && ch <= y1
+}
+
+func (s *sequencerModel) cellAt(ch, step int) cell {
+	return cell{
+		on:       s.steps[ch][step],
+		note:     s.notes[ch][step],
+		velocity: s.velocities[ch][step],
+		gate:     s.gates[ch][step],
+		tie:      s.ties[ch][step],
+		chord:    slices.Clone(s.chords[ch][step]),
+		nudge:    s.nudges[ch][step],
+		chance:   s.chances[ch][step],
+		cond:     s.conditions[ch][step],
+		ratchet:  s.ratchets[ch][step],
+		lanes:    s.laneSteps(ch, step),
+	}
+}
+
+func (s *sequencerModel) setCell(ch, step int, c cell) {
+	s.steps[ch][step] = c.on
+	s.notes[ch][step] = c.note
+	s.velocities[ch][step] = c.velocity
+	s.gates[ch][step] = c.gate
+	s.ties[ch][step] = c.tie
+	s.chords[ch][step] = slices.Clone(c.chord)
+	s.nudges[ch][step] = c.nudge
+	s.chances[ch][step] = c.chance
+	s.conditions[ch][step] = c.cond
+	s.ratchets[ch][step] = c.ratchet
+	for l, st := range c.lanes {
+		s.lanes[ch][l].steps[step] = st
+	}
+}
+
+// laneSteps returns a step of each of a channel's CC lanes.
+func (s *sequencerModel) laneSteps(ch, step int) [numLanes]laneStep {
+	var steps [numLanes]laneStep
+	for l := range s.lanes[ch] {
+		steps[l] = s.lanes[ch][l].steps[step]
+	}
+	return steps
+}
+
+// copySelection puts the selected steps on the clipboard.
+func (s *sequencerModel) copySelection() {
+	x0, y0, x1, y1 := s.selection()
+	clip := make(clipboard, 0, y1-y0+1)
+	for ch := y0; ch <= y1; ch++ {
+		row := make([]cell, 0, x1-x0+1)
+		for step := x0; step <= x1; step++ {
+			row = append(row, s.cellAt(ch, step))
+		}
+		clip = append(clip, row)
+	}
+	s.clipboard = clip
+	s.message = fmt.Sprintf("Copied %d step(s) on %d channel(s)", x1-x0+1, y1-y0+1)
+}
+
+// cutSelection copies the selected steps and clears them. Notes stay, so the
+// channel keeps its pitch; everything else goes back to its default.
+func (s *sequencerModel) cutSelection() {
+	s.copySelection()
+	x0, y0, x1, y1 := s.selection()
+	for ch := y0; ch <= y1; ch++ {
+		for step := x0; step <= x1; step++ {
+			s.setCell(ch, step, cell{note: s.notes[ch][step], velocity: defaultVelocity, gate: maxGate, chance: maxChance, ratchet: 1})
+		}
+	}
+	s.message = fmt.Sprintf("Cut %d step(s) on %d channel(s)", x1-x0+1, y1-y0+1)
+}
+
+// pasteAt writes the clipboard with its top left corner at the given step.
+// Whatever would land past the last step or channel is dropped. It returns how
+// many steps were pasted on the first row.
+func (s *sequencerModel) pasteAt(ch, step int) int {
+	pasted := 0
+	for y, row := range s.clipboard {
+		for x, c := range row {
+			if ch+y >= numChannels || step+x >= numSteps {
+				continue
+			}
+			s.setCell(ch+y, step+x, c)
+			if y == 0 {
+				pasted++
+			}
+		}
+	}
+	return pasted
+}
+
+// paste writes the clipboard at the cursor, or at the top left of the
+// selection.
+func (s *sequencerModel) paste() {
+	if len(s.clipboard) == 0 {
+		s.message = "Nothing to paste: select steps with v and copy them with y"
+		return
+	}
+	x0, y0, _, _ := s.selection()
+	pasted := s.pasteAt(y0, x0)
+	if pasted < len(s.clipboard[0]) {
+		s.message = fmt.Sprintf("Pasted %d of %d step(s); the rest fell past the end of the pattern", pasted, len(s.clipboard[0]))
+		return
+	}
+	s.message = fmt.Sprintf("Pasted %d step(s)", pasted)
+}
+
+// duplicateRight copies the selection into the steps just after it and
+// selects the copy, so pressing again keeps extending the phrase.
+func (s *sequencerModel) duplicateRight() {
+	x0, y0, x1, y1 := s.selection()
+	width := x1 - x0 + 1
+	if x1+width >= numSteps {
+		s.message = "No room to the right to duplicate the selection"
+		return
+	}
+	saved := s.clipboard
+	s.copySelection()
+	s.pasteAt(y0, x1+1)
+	s.clipboard = saved
+
+	if s.selecting {
+		s.anchorX, s.anchorY = x1+1, y0
+		s.cursorX, s.cursorY = x1+width, y1
+	} else {
+		s.cursorX = x1 + 1
+	}
+	s.message = fmt.Sprintf("Duplicated %d step(s) to the right", width)
+}
+
+// fillEvery repeats the selection every n steps from its start to the end of
+// the pattern.
+func (s *sequencerModel) fillEvery(n int) {
+	x0, y0, x1, _ := s.selection()
+	saved := s.clipboard
+	s.copySelection()
+	copies := 0
+	for step := x0 + n; step < numSteps; step += n {
+		s.pasteAt(y0, step)
+		copies++
+	}
+	s.clipboard = saved
+	s.selecting = false
+	if copies == 0 {
+		s.message = fmt.Sprintf("Every %d steps doesn't fit another copy before the end of the pattern", n)
+		return
+	}
+	s.message = fmt.Sprintf("Filled %d step(s) every %d steps (%d copies)", x1-x0+1, n, copies)
+}
+
+// openFill asks how often to repeat the selection, starting from its width.
+func (s *sequencerModel) openFill() {
+	x0, _, x1, _ := s.selection()
+	s.fillPrompt = &textPrompt{title: "Fill Every N Steps", value: strconv.Itoa(x1 - x0 + 1)}
+}
+
+// updateFill handles keys in the fill prompt.
+func (s *sequencerModel) updateFill(msg tea.KeyMsg) {
+	p := s.fillPrompt
+	submitted, cancelled := p.update(msg)
+	if cancelled {
+		s.fillPrompt = nil
+		return
+	}
+	if !submitted {
+		return
+	}
+	n, err := strconv.Atoi(p.value)
+	if err != nil || n < 1 || n >= numSteps {
+		p.err = fmt.Sprintf("enter a number of steps from 1 to %d", numSteps-1)
+		return
+	}
+	s.fillPrompt = nil
+	s.fillEvery(n)
+}
+
+// updateSelection handles the keys that act on the visual selection. It
+// reports whether the key was used; anything else, such as moving the
+// cursor to grow the selection, goes on to the normal keys.
+func (s *sequencerModel) updateSelection(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "v", "esc":
+		s.selecting = false
+	case "y":
+		s.copySelection()
+		s.selecting = false
+	case "x":
+		s.cutSelection()
+		s.selecting = false
+	case "ctrl+v":
+		s.paste()
+		s.selecting = false
+	case "D":
+		s.duplicateRight()
+	case "f":
+		s.openFill()
+	default:
+		return false
+	}
+	return true
+}
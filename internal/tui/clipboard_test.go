@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCopyPasteSelection(t *testing.T) {
+	dir := t.TempDir()
+
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(dir, "from.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s := &m.sequencer
+	s.steps[0][0] = true
+	s.notes[0][0] = 48
+	s.velocities[0][0] = 40
+	s.chords[0][0] = []int{4, 7}
+	s.steps[1][1] = true
+
+	// Select channels 1-2, steps 0-1, and copy
+	m = pressKeys(m, "v", "l", "j", "y")
+	if m.sequencer.selecting || len(m.sequencer.clipboard) != 2 || len(m.sequencer.clipboard[0]) != 2 {
+		t.Fatalf("Expected a 2x2 clipboard, got %d rows", len(m.sequencer.clipboard))
+	}
+
+	// The clipboard survives opening another file
+	if err := m.sequencer.createNewMIDI(filepath.Join(dir, "to.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	m.sequencer.cursorX, m.sequencer.cursorY = 8, 2
+	m = pressKeys(m, "ctrl+v")
+	s = &m.sequencer
+	if !s.steps[2][8] || s.notes[2][8] != 48 || s.velocities[2][8] != 40 || !slices.Equal(s.chords[2][8], []int{4, 7}) {
+		t.Error("Expected the step to be pasted with its note, velocity and chord")
+	}
+	if !s.steps[3][9] {
+		t.Error("Expected the second channel to be pasted below")
+	}
+
+	// Pasted chords are copies, not shared with the clipboard
+	s.chords[2][8][0] = 3
+	if m.sequencer.clipboard[0][0].chord[0] != 4 {
+		t.Error("Expected editing a pasted chord to leave the clipboard alone")
+	}
+
+	// Cutting clears the steps but keeps them on the clipboard
+	m.sequencer.cursorX, m.sequencer.cursorY = 8, 2
+	m = pressKeys(m, "v", "x")
+	s = &m.sequencer
+	if s.steps[2][8] || s.chords[2][8] != nil || len(s.clipboard) != 1 || !s.clipboard[0][0].on {
+		t.Error("Expected cut to clear the step and copy it")
+	}
+}
+
+func TestDuplicateAndFill(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "fill.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	m.sequencer.steps[0][0] = true
+
+	// Duplicating a two-step selection selects the copy
+	m = pressKeys(m, "v", "l", "D")
+	s := &m.sequencer
+	if !s.steps[0][2] || s.steps[0][3] || s.anchorX != 2 || s.cursorX != 3 {
+		t.Errorf("Expected steps 2-3 to be a selected copy, anchor %d cursor %d", s.anchorX, s.cursorX)
+	}
+
+	// Fill every 4 steps from the new selection
+	m = pressKeys(m, "f", "backspace", "4", "enter")
+	s = &m.sequencer
+	var got []int
+	for step := range numSteps {
+		if s.steps[0][step] {
+			got = append(got, step)
+		}
+	}
+	if want := []int{0, 2, 6, 10, 14}; !slices.Equal(got, want) {
+		t.Errorf("Expected steps %v, got %v", want, got)
+	}
+	if s.selecting || s.fillPrompt != nil {
+		t.Error("Expected filling to end the selection")
+	}
+
+	// Undo takes the fill back in one step
+	m = pressKeys(m, "u")
+	if m.sequencer.steps[0][6] {
+		t.Error("Expected undo to remove the fill")
+	}
+}
@@ -0,0 +1,177 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// euclidField is the setting highlighted in the Euclidean rhythm overlay.
+type euclidField int
+
+const (
+	euclidHits euclidField = iota
+	euclidLength
+	euclidRotation
+	numEuclidFields
+)
+
+// euclidRhythm is a Euclidean rhythm: hits spread as evenly as possible over
+// length steps, rotated right by rotation steps.
+type euclidRhythm struct {
+	hits     int
+	length   int
+	rotation int
+	field    euclidField // Setting being changed in the overlay
+}
+
+// euclid returns which of the rhythm's steps are hits, using Bjorklund's
+// algorithm: start with a group per step, then keep appending the leftover
+// groups onto the others until at most one is left over.
+func (r euclidRhythm) euclid() []bool {
+	var base []bool
+	if r.hits == 0 {
+		base = make([]bool, r.length)
+	} else {
+		a := slices.Repeat([][]bool{{true}}, r.hits)
+		b := slices.Repeat([][]bool{{false}}, r.length-r.hits)
+		for len(b) > 1 {
+			n := min(len(a), len(b))
+			joined := make([][]bool, n)
+			for i := range n {
+				joined[i] = slices.Concat(a[i], b[i])
+			}
+			if len(a) > n {
+				a, b = joined, a[n:]
+			} else {
+				a, b = joined, b[n:]
+			}
+		}
+		base = slices.Concat(slices.Concat(a...), slices.Concat(b...))
+	}
+	rotated := make([]bool, r.length)
+	for i := range rotated {
+		rotated[(i+r.rotation)%r.length] = base[i]
+	}
+	return rotated
+}
+
+// stepsFor lays the rhythm out over a whole track, repeating it when it is
+// shorter than the pattern.
+func (r euclidRhythm) stepsFor() [numSteps]bool {
+	var steps [numSteps]bool
+	hits := r.euclid()
+	for step := range steps {
+		steps[step] = hits[step%r.length]
+	}
+	return steps
+}
+
+// openEuclid starts the overlay with four hits over the whole pattern, or
+// with as many hits as the track already has.
+func (s *sequencerModel) openEuclid() {
+	hits := 0
+	for _, on := range s.steps[s.cursorY] {
+		if on {
+			hits++
+		}
+	}
+	if hits == 0 {
+		hits = 4
+	}
+	s.euclid = &euclidRhythm{hits: hits, length: numSteps}
+}
+
+// change moves the highlighted setting by delta, keeping hits and rotation
+// within the length.
+func (r *euclidRhythm) change(delta int) {
+	switch r.field {
+	case euclidHits:
+		r.hits = min(max(r.hits+delta, 0), r.length)
+	case euclidLength:
+		r.length = min(max(r.length+delta, 1), numSteps)
+		r.hits = min(r.hits, r.length)
+		r.rotation = min(r.rotation, r.length-1)
+	case euclidRotation:
+		r.rotation = (r.rotation + delta + r.length) % r.length
+	}
+}
+
+// updateEuclid handles keys in the Euclidean rhythm overlay. Nothing changes
+// on the track until enter.
+func (s *sequencerModel) updateEuclid(msg tea.KeyMsg) {
+	r := s.euclid
+	switch msg.String() {
+	case keyLeft, "h":
+		r.field = (r.field + numEuclidFields - 1) % numEuclidFields
+	case keyRight, "l", "tab":
+		r.field = (r.field + 1) % numEuclidFields
+	case keyUp, "k", "+", "=":
+		r.change(1)
+	case keyDown, "j", "-", "_":
+		r.change(-1)
+	case "enter":
+		ch := s.cursorY
+		s.steps[ch] = r.stepsFor()
+		// Old ties would join neighbouring hits into long notes
+		s.ties[ch] = [numSteps]bool{}
+		s.euclid = nil
+		s.message = fmt.Sprintf("Ch %d: %d hits over %d steps, rotated %d", ch+1, r.hits, r.length, r.rotation)
+	case "esc", "q", "R":
+		s.euclid = nil
+	}
+}
+
+func (m model) viewEuclid() string {
+	s := m.sequencer
+	r := s.euclid
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Euclidean Rhythm") + "\n\n")
+
+	values := [numEuclidFields]string{
+		fmt.Sprintf("Hits: %d", r.hits),
+		fmt.Sprintf("Steps: %d", r.length),
+		fmt.Sprintf("Rotation: %d", r.rotation),
+	}
+	for field, value := range values {
+		if euclidField(field) == r.field {
+			b.WriteString(selectedStyle.Render("> "+value) + "   ")
+		} else {
+			b.WriteString("  " + value + "   ")
+		}
+	}
+	b.WriteString("\n\n")
+
+	// Preview the new track under the current one
+	preview := r.stepsFor()
+	rows := []struct {
+		label string
+		steps [numSteps]bool
+	}{{fmt.Sprintf("Ch %d now", s.cursorY+1), s.steps[s.cursorY]}, {"Preview", preview}}
+	for i, row := range rows {
+		fmt.Fprintf(&b, "%-10s", row.label)
+		for step, on := range row.steps {
+			cellStyle := lipgloss.NewStyle().Width(3).Align(lipgloss.Center).Foreground(lipgloss.Color("#666666"))
+			cell := "·"
+			if on {
+				cell = "●"
+				cellStyle = cellStyle.Foreground(velocityColor(defaultVelocity))
+			}
+			// Mark where each repeat of a shorter rhythm starts
+			if i == 1 && r.length < numSteps && step%r.length == 0 {
+				cellStyle = cellStyle.Underline(true)
+			}
+			b.WriteString(cellStyle.Render(cell))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n" + helpStyle.Render("←/→: choose setting • ↑/↓: change • enter: fill track • esc: cancel"))
+
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// rhythmString draws hits as x and rests as dots.
+func rhythmString(hits []bool) string {
+	var s []byte
+	for _, on := range hits {
+		if on {
+			s = append(s, 'x')
+		} else {
+			s = append(s, '.')
+		}
+	}
+	return string(s)
+}
+
+func TestEuclid(t *testing.T) {
+	tests := []struct {
+		r    euclidRhythm
+		want string
+	}{
+		{euclidRhythm{hits: 3, length: 8}, "x..x..x."},
+		{euclidRhythm{hits: 5, length: 8}, "x.xx.xx."},
+		{euclidRhythm{hits: 4, length: 16}, "x...x...x...x..."},
+		{euclidRhythm{hits: 3, length: 8, rotation: 2}, "x.x..x.."},
+		{euclidRhythm{hits: 0, length: 5}, "....."},
+		{euclidRhythm{hits: 5, length: 5}, "xxxxx"},
+	}
+	for _, tt := range tests {
+		if got := rhythmString(tt.r.euclid()); got != tt.want {
+			t.Errorf("E(%d,%d) rotated %d: expected %s, got %s", tt.r.hits, tt.r.length, tt.r.rotation, tt.want, got)
+		}
+	}
+}
+
+func TestEuclidFillsTrack(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "euclid.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	m.sequencer.cursorY = 1
+	m.sequencer.ties[1][0] = true
+
+	// Three hits over eight steps; the preview leaves the track alone
+	m = pressKeys(m, "R", "j", "l")
+	for range 8 {
+		m = pressKeys(m, "j")
+	}
+	if m.sequencer.euclid.hits != 3 || m.sequencer.euclid.length != 8 {
+		t.Fatalf("Expected E(3,8), got E(%d,%d)", m.sequencer.euclid.hits, m.sequencer.euclid.length)
+	}
+	if slices.Contains(m.sequencer.steps[1][:], true) {
+		t.Fatal("Expected the track to stay empty until enter")
+	}
+
+	m = pressKeys(m, "enter")
+	s := &m.sequencer
+	if got := rhythmString(s.steps[1][:]); got != "x..x..x.x..x..x." {
+		t.Errorf("Expected the rhythm repeated over the track, got %s", got)
+	}
+	if s.ties[1][0] || s.euclid != nil {
+		t.Error("Expected the overlay to close and old ties to be cleared")
+	}
+}
@@ -0,0 +1,307 @@
+package tui
+
+import (
+	"sort"
+
+	"github.com/icco/genidi/internal/arp"
+	"gitlab.com/gomidi/midi/v2"
+)
+
+// noteEvent is a note on or off, or a control change, positioned in SMF
+// ticks from the start of the pattern. saveMIDI writes these out track by
+// track and playback walks them in time, so the exported file grooves the
+// same way as playback.
+type noteEvent struct {
+	tick     uint32
+	channel  uint8
+	note     uint8    // The controller, for a control change
+	velocity uint8    // zero for note off; the value, for a control change
+	control  bool     // Whether this is a control change rather than a note
+	trig     int      // trigID of the step that plays it, or zero when it always plays
+	rule     trigRule // When the step plays, if trig is set
+}
+
+// isNoteOff reports whether the event releases a note.
+func (ev noteEvent) isNoteOff() bool {
+	return !ev.control && ev.velocity == 0
+}
+
+// message is the MIDI message the event sends.
+func (ev noteEvent) message() midi.Message {
+	switch {
+	case ev.control:
+		return midi.ControlChange(ev.channel, ev.note, ev.velocity)
+	case ev.velocity > 0:
+		return midi.NoteOn(ev.channel, ev.note, ev.velocity)
+	default:
+		return midi.NoteOff(ev.channel, ev.note)
+	}
+}
+
+// order ranks events on the same tick: note offs, then control changes, then
+// note ons.
+func (ev noteEvent) order() int {
+	switch {
+	case ev.isNoteOff():
+		return 0
+	case ev.control:
+		return 1
+	default:
+		return 2
+	}
+}
+
+const (
+	// ticksPerStep is the length of one 16th note step in SMF ticks.
+	ticksPerStep = uint32(ticksPerQuarterNote / 4) // 240 ticks per step
+	// patternTicks is the length of the whole pattern in SMF ticks.
+	patternTicks = numSteps * ticksPerStep
+)
+
+// swingTicks is how far the swing setting delays a step. Only the off-beat
+// 16ths (odd steps) move: at 50% they are straight, at 66% they fall on a
+// triplet and at 75% they sit halfway to the next on-beat.
+func (s *sequencerModel) swingTicks(step int) uint32 {
+	if step%2 == 0 || s.swing <= minSwing {
+		return 0
+	}
+	return ticksPerStep * uint32(min(s.swing, maxSwing)*2-100) / 100 //nolint:gosec // swing is clamped to minSwing..maxSwing
+}
+
+// stepPosition is where a step starts once swing is applied, before any
+// per-step nudge.
+func (s *sequencerModel) stepPosition(step int) uint32 {
+	return uint32(step)*ticksPerStep + s.swingTicks(step) //nolint:gosec // step is bounded by numSteps constant
+}
+
+// stepWindow is the time between a step and the next one. Swing lengthens
+// the on-beats and shortens the off-beats.
+func (s *sequencerModel) stepWindow(step int) uint32 {
+	return s.stepPosition(step+1) - s.stepPosition(step)
+}
+
+// nudgeTicks converts a step's micro-timing nudge into a signed tick offset.
+func (s *sequencerModel) nudgeTicks(ch, step int) int {
+	return int(ticksPerStep) * min(max(s.nudges[ch][step], -maxNudge), maxNudge) / 100
+}
+
+// gateTicks converts a gate percentage into a note length within a step
+// window. A full gate stops one tick short so the next step's note on never
+// overlaps.
+func gateTicks(gate int, window uint32) uint32 {
+	if gate >= maxGate {
+		return window - 1
+	}
+	return max(window*uint32(max(gate, minGate))/100, 1) //nolint:gosec // gate is clamped to minGate..maxGate
+}
+
+// tiedToNext reports whether the notes on a step hold into the following
+// step instead of being released. Ties only join steps playing the same notes
+// and never ratcheted steps.
+func (s *sequencerModel) tiedToNext(ch, step int) bool {
+	return s.steps[ch][step] && s.ties[ch][step] && step+1 < numSteps &&
+		s.steps[ch][step+1] && s.sameChord(ch, step, step+1) &&
+		s.ratchet(ch, step) == 1 && s.ratchet(ch, step+1) == 1
+}
+
+// chainEnd returns the last step of the tie chain starting at step.
+func (s *sequencerModel) chainEnd(ch, step int) int {
+	for s.tiedToNext(ch, step) {
+		step++
+	}
+	return step
+}
+
+// isTieContinuation reports whether a step only sustains a note started on an
+// earlier step.
+func (s *sequencerModel) isTieContinuation(ch, step int) bool {
+	return step > 0 && s.tiedToNext(ch, step-1)
+}
+
+// gate returns the step's gate percentage, falling back to a full gate for
+// steps that were never given one.
+func (s *sequencerModel) gate(ch, step int) int {
+	g := s.gates[ch][step]
+	if g < minGate || g > maxGate {
+		return maxGate
+	}
+	return g
+}
+
+// startsNote reports whether a step starts a note rather than being off or
+// holding a tied one.
+func (s *sequencerModel) startsNote(ch, step int) bool {
+	return s.steps[ch][step] && !s.isTieContinuation(ch, step)
+}
+
+// noteStart is where a step's note starts. A nudge moves the whole note;
+// notes can't be pulled before the pattern starts.
+func (s *sequencerModel) noteStart(ch, step int) uint32 {
+	return uint32(max(int(s.stepPosition(step))+s.nudgeTicks(ch, step), 0)) //nolint:gosec // clamped to zero
+}
+
+// trig returns the trigID and rule of a step, with a zero trigID when the
+// step plays on every pass.
+func (s *sequencerModel) trig(ch, step int) (int, trigRule) {
+	rule := s.trigRule(ch, step)
+	if rule.always() {
+		return 0, rule
+	}
+	return trigID(ch, step), rule
+}
+
+// channelEvents renders one channel of the pattern into note events, joining
+// tied steps into a single long note, splitting ratcheted steps into repeats,
+// arpeggiating when the channel's arpeggiator is on and applying swing and
+// nudges, along with the channel's CC lanes. Every step is included; events
+// of steps with a chance or condition carry the rule deciding when they play.
+func (s *sequencerModel) channelEvents(ch int) []noteEvent {
+	events := s.laneEvents(ch)
+	for step := 0; step < numSteps; step++ {
+		if !s.startsNote(ch, step) {
+			continue
+		}
+
+		last := s.chainEnd(ch, step)
+		start := s.noteStart(ch, step)
+		length := s.stepPosition(last) - s.stepPosition(step) + gateTicks(s.gate(ch, last), s.stepWindow(last))
+		channel := uint8(ch) //nolint:gosec // ch is bounded by numChannels constant
+		trig, rule := s.trig(ch, step)
+
+		// The arpeggiator plays the step's notes one at a time instead
+		if a := s.arp(ch); a.Mode != arp.Off {
+			events = append(events, s.arpEvents(ch, step, a, start, length, trig, rule)...)
+			continue
+		}
+
+		// A ratchet repeats the note evenly across the step, each repeat gated
+		repeats, every := s.ratchet(ch, step), uint32(0)
+		if repeats > 1 {
+			every = s.stepWindow(step) / uint32(repeats) //nolint:gosec // repeats is bounded by maxRatchet
+			length = gateTicks(s.gate(ch, step), every)
+		}
+		for i := range uint32(repeats) { //nolint:gosec // repeats is bounded by maxRatchet
+			at := start + i*every
+			for _, note := range s.stepNotes(ch, step) {
+				events = append(events,
+					noteEvent{tick: at, channel: channel, note: note, velocity: s.velocity(ch, step), trig: trig, rule: rule},
+					noteEvent{tick: at + length, channel: channel, note: note, trig: trig, rule: rule},
+				)
+			}
+		}
+	}
+
+	sortEvents(events)
+	return events
+}
+
+// patternEvents renders every channel into a single time-ordered list.
+func (s *sequencerModel) patternEvents() []noteEvent {
+	var events []noteEvent
+	for ch := 0; ch < numChannels; ch++ {
+		events = append(events, s.channelEvents(ch)...)
+	}
+	sortEvents(events)
+	return events
+}
+
+// sortEvents orders events by tick. Note offs sort ahead of note ons on the
+// same tick so a retriggered note is released before it starts again, and
+// control changes go between so the note starts with its controller values.
+func sortEvents(events []noteEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].tick != events[j].tick {
+			return events[i].tick < events[j].tick
+		}
+		return events[i].order() < events[j].order()
+	})
+}
+
+// importedNote is a note read from an SMF track, before it is placed on the grid.
+type importedNote struct {
+	start    uint32
+	length   uint32
+	key      uint8
+	velocity uint8
+}
+
+// nearestStep finds the step whose swung position is closest to tick and
+// returns the remaining offset as a nudge percentage.
+func (s *sequencerModel) nearestStep(tick uint32) (step, nudge int) {
+	best := -1
+	var bestDist int
+	for i := 0; i < numSteps; i++ {
+		dist := int(tick) - int(s.stepPosition(i))
+		if best < 0 || abs(dist) < abs(bestDist) {
+			best, bestDist = i, dist
+		}
+	}
+	nudge = (bestDist*100 + sign(bestDist)*int(ticksPerStep)/2) / int(ticksPerStep)
+	return best, min(max(nudge, -maxNudge), maxNudge)
+}
+
+// placeNote writes an imported note into the grid. Notes longer than a step
+// become a chain of tied steps; the remainder sets the final step's gate. A
+// note starting on a step that already starts a note joins it as a chord.
+// Notes must be placed in order of their start tick.
+func (s *sequencerModel) placeNote(ch int, start, length uint32, key, velocity uint8) {
+	if start >= patternTicks {
+		return
+	}
+	step, nudge := s.nearestStep(start)
+	if s.steps[ch][step] && !s.isTieContinuation(ch, step) {
+		s.addChordNote(ch, step, int(key))
+		return
+	}
+	if s.isTieContinuation(ch, step) {
+		// A new note cuts off the one held into this step
+		s.ties[ch][step-1] = false
+	}
+	if length == 0 {
+		length = s.stepWindow(step) - 1
+	}
+
+	// Walk forward a step window at a time until the note ends
+	last, remainder := step, length
+	for remainder > s.stepWindow(last) && last < numSteps-1 {
+		remainder -= s.stepWindow(last)
+		last++
+	}
+	remainder = min(remainder, s.stepWindow(last))
+
+	for i := step; i <= last; i++ {
+		s.steps[ch][i] = true
+		s.notes[ch][i] = int(key)
+		s.velocities[ch][i] = int(velocity)
+		s.chords[ch][i] = nil
+		s.gates[ch][i] = maxGate
+		s.nudges[ch][i] = 0
+		s.chances[ch][i] = maxChance
+		s.conditions[ch][i] = trigCondition{}
+		s.ratchets[ch][i] = 1
+		s.ties[ch][i] = i < last
+	}
+	s.nudges[ch][step] = nudge
+
+	// A full gate is written one tick short of the window, see gateTicks
+	window := s.stepWindow(last)
+	gate := int((remainder*100 + window/2) / window)
+	if remainder+1 >= window {
+		gate = maxGate
+	}
+	s.gates[ch][last] = min(max(gate, minGate), maxGate)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sign(n int) int {
+	if n < 0 {
+		return -1
+	}
+	return 1
+}
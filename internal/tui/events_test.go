@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSwingDelaysOffBeats(t *testing.T) {
+	s := &sequencerModel{swing: 75}
+
+	if got := s.stepPosition(0); got != 0 {
+		t.Errorf("Expected step 0 at tick 0, got %d", got)
+	}
+	// 75% swing pushes the off-beat halfway to the next on-beat
+	if got, want := s.stepPosition(1), ticksPerStep+ticksPerStep/2; got != want {
+		t.Errorf("Expected step 1 at tick %d, got %d", want, got)
+	}
+	if got, want := s.stepPosition(2), 2*ticksPerStep; got != want {
+		t.Errorf("Expected step 2 at tick %d, got %d", want, got)
+	}
+}
+
+func TestSwingAndNudgeRoundTrip(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "groove.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	s.swing = 62
+	s.steps[0][1] = true
+	s.steps[0][4] = true
+	s.nudges[0][4] = -20
+	s.steps[1][9] = true
+	s.nudges[1][9] = 15
+
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	// The exported note ons carry the groove
+	events := s.channelEvents(0)
+	if got, want := events[0].tick, s.stepPosition(1); got != want {
+		t.Errorf("Expected swung note on at tick %d, got %d", want, got)
+	}
+	if got, want := events[2].tick, 4*ticksPerStep-48; got != want {
+		t.Errorf("Expected nudged note on at tick %d, got %d", want, got)
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+
+	if s2.swing != 62 {
+		t.Errorf("Expected swing 62, got %d", s2.swing)
+	}
+	for _, tt := range []struct{ ch, step, nudge int }{{0, 1, 0}, {0, 4, -20}, {1, 9, 15}} {
+		if !s2.steps[tt.ch][tt.step] {
+			t.Errorf("Expected step[%d][%d] to be active", tt.ch, tt.step)
+		}
+		if got := s2.nudges[tt.ch][tt.step]; got != tt.nudge {
+			t.Errorf("Expected nudge[%d][%d] = %d, got %d", tt.ch, tt.step, tt.nudge, got)
+		}
+		if got := s2.gate(tt.ch, tt.step); got != maxGate {
+			t.Errorf("Expected gate[%d][%d] to stay full, got %d", tt.ch, tt.step, got)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// rhythmStyle is how the generator chooses which steps play.
+type rhythmStyle int
+
+const (
+	styleRandom   rhythmStyle = iota // Any steps
+	styleStraight                    // Beats first, then eighths, then sixteenths
+	styleOffbeat                     // Off-beat eighths first, then sixteenths, then beats
+	styleEuclid                      // Hits spread evenly, at a random rotation
+	numStyles
+)
+
+var rhythmStyleNames = [numStyles]string{"random", "straight", "offbeat", "euclidean"}
+
+const (
+	defaultDensity   = 50 // Percentage of steps a generated channel plays
+	densityIncrement = 5
+	defaultLowNote   = 48 // C3
+	defaultHighNote  = 72 // C5
+	accentVelocity   = 120
+	velocitySpread   = 24 // How far below the default unaccented steps may fall
+)
+
+// RhythmStyleNames lists the rhythm styles the generator offers.
+func RhythmStyleNames() []string {
+	return rhythmStyleNames[:]
+}
+
+// parseRhythmStyle finds a rhythm style by name.
+func parseRhythmStyle(name string) (rhythmStyle, error) {
+	for i, n := range rhythmStyleNames {
+		if strings.EqualFold(n, name) {
+			return rhythmStyle(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rhythm style %q: choose from %s", name, strings.Join(RhythmStyleNames(), ", "))
+}
+
+// generator builds channels of a pattern from constraints. The same settings
+// and seed always give the same pattern.
+type generator struct {
+	density int // Percentage of steps that play
+	key     scaleLock
+	low     int // Lowest note
+	high    int // Highest note
+	style   rhythmStyle
+	seed    uint64
+}
+
+// hits picks which steps play.
+func (g *generator) hits(rng *rand.Rand) [numSteps]bool {
+	n := (g.density*numSteps + 50) / 100
+	if g.style == styleEuclid {
+		return euclidRhythm{hits: n, length: numSteps, rotation: rng.IntN(numSteps)}.stepsFor()
+	}
+	order := rng.Perm(numSteps)
+	if g.style != styleRandom {
+		// Steps of the same rank stay in random order
+		slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(g.rank(a), g.rank(b)) })
+	}
+	var steps [numSteps]bool
+	for _, step := range order[:n] {
+		steps[step] = true
+	}
+	return steps
+}
+
+// rank orders steps by how much the style wants them to play, lowest first.
+func (g *generator) rank(step int) int {
+	beat, eighth := step%4 == 0, step%2 == 0
+	switch {
+	case g.style == styleOffbeat && beat:
+		return 2
+	case g.style == styleOffbeat && eighth:
+		return 0
+	case g.style == styleOffbeat:
+		return 1
+	case beat:
+		return 0
+	case eighth:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// pool returns the scale notes in range, lowest first.
+func (g *generator) pool() []int {
+	var pool []int
+	for note := g.low; note <= g.high; note++ {
+		if g.key.inScale(note) {
+			pool = append(pool, note)
+		}
+	}
+	return pool
+}
+
+// melody walks up and down the scale notes in range, mostly by small steps.
+// The range must hold at least one of them.
+func (g *generator) melody(rng *rand.Rand) [numSteps]int {
+	pool := g.pool()
+	var notes [numSteps]int
+	i := rng.IntN(len(pool))
+	for step := range notes {
+		notes[step] = pool[i]
+		i = min(max(i+rng.IntN(5)-2, 0), len(pool)-1)
+	}
+	return notes
+}
+
+// dynamics accents the beats and varies the other steps a little.
+func (g *generator) dynamics(rng *rand.Rand) [numSteps]int {
+	var velocities [numSteps]int
+	for step := range velocities {
+		if step%4 == 0 {
+			velocities[step] = accentVelocity
+		} else {
+			velocities[step] = defaultVelocity - rng.IntN(velocitySpread)
+		}
+	}
+	return velocities
+}
+
+// fill replaces a channel of p with a generated one. Each channel draws from
+// its own stream of the seed, so generating one channel gives the same
+// result as that channel of a whole generated pattern.
+func (g *generator) fill(p *pattern, ch int) {
+	rng := rand.New(rand.NewPCG(g.seed, uint64(ch))) //nolint:gosec // musical randomness, not security; ch is bounded by numChannels
+	p.resetChannel(ch)
+	p.steps[ch] = g.hits(rng)
+	p.notes[ch] = g.melody(rng)
+	p.velocities[ch] = g.dynamics(rng)
+}
+
+// GenerateOptions are the constraints genidi generate builds a pattern from.
+type GenerateOptions struct {
+	Density int    // Percentage of steps that play
+	Root    string // Tonic, such as "D" or "F#"
+	Scale   string // One of ScaleNames
+	Low     string // Lowest note, such as "C3"
+	High    string // Highest note
+	Style   string // One of RhythmStyleNames
+	Seed    uint64 // Zero picks a seed
+	BPM     int
+}
+
+// generator checks the options and turns them into a generator.
+func (o GenerateOptions) generator() (generator, error) {
+	g := generator{density: o.Density, seed: o.Seed}
+	if g.density < 0 || g.density > 100 {
+		return g, fmt.Errorf("density %d%% is not between 0 and 100", o.Density)
+	}
+	var err error
+	if g.key.root, err = parseRoot(o.Root); err != nil {
+		return g, err
+	}
+	if g.key.scale, err = scaleIndex(o.Scale); err != nil {
+		return g, err
+	}
+	if g.low, err = parseNoteName(o.Low); err != nil {
+		return g, err
+	}
+	if g.high, err = parseNoteName(o.High); err != nil {
+		return g, err
+	}
+	if g.low > g.high {
+		return g, fmt.Errorf("lowest note %s is above highest note %s", o.Low, o.High)
+	}
+	if len(g.pool()) == 0 {
+		return g, fmt.Errorf("no notes of %s between %s and %s", g.key.name(), o.Low, o.High)
+	}
+	if g.style, err = parseRhythmStyle(o.Style); err != nil {
+		return g, err
+	}
+	if g.seed == 0 {
+		g.seed = newSeed()
+	}
+	return g, nil
+}
+
+// Generate writes a new MIDI file at path with every channel of the first
+// pattern generated from opts, and returns the seed used. The scale is kept
+// as the file's scale lock, and the seed also seeds the file's probability
+// steps, so the same options always give the same file.
+func Generate(path string, opts GenerateOptions) (uint64, error) {
+	g, err := opts.generator()
+	if err != nil {
+		return 0, err
+	}
+	if opts.BPM < minBPM || opts.BPM > maxBPM {
+		return 0, fmt.Errorf("tempo %d BPM is not between %d and %d", opts.BPM, minBPM, maxBPM)
+	}
+
+	s := &sequencerModel{filePath: path, bpm: opts.BPM, swing: minSwing, key: g.key, seed: g.seed}
+	s.resetBank()
+	s.resetMix()
+	for ch := range numChannels {
+		g.fill(&s.pattern, ch)
+	}
+	return g.seed, s.saveMIDI()
+}
+
+// generatorField is the setting highlighted in the generator overlay.
+type generatorField int
+
+const (
+	genDensity generatorField = iota
+	genStyle
+	genLow
+	genHigh
+	genScope
+	numGeneratorFields
+)
+
+// generatorEditor is the generator being set up in the overlay.
+type generatorEditor struct {
+	generator
+	wholePattern bool // Fill every channel rather than the cursor's
+	field        generatorField
+}
+
+// openGenerator starts the overlay on the cursor's channel with a new seed,
+// in the project's scale.
+func (s *sequencerModel) openGenerator() {
+	s.generating = &generatorEditor{generator: generator{
+		density: defaultDensity,
+		key:     s.key,
+		low:     defaultLowNote,
+		high:    defaultHighNote,
+		style:   styleStraight,
+		seed:    newSeed(),
+	}}
+}
+
+// change moves the highlighted setting, keeping the lowest note at or below
+// the highest.
+func (e *generatorEditor) change(delta int) {
+	switch e.field {
+	case genDensity:
+		e.density = min(max(e.density+delta*densityIncrement, 0), 100)
+	case genStyle:
+		e.style = (e.style + numStyles + rhythmStyle(delta)) % numStyles
+	case genLow:
+		e.low = min(max(e.low+delta, minMIDINote), e.high)
+	case genHigh:
+		e.high = min(max(e.high+delta, e.low), maxMIDINote)
+	case genScope:
+		e.wholePattern = !e.wholePattern
+	}
+}
+
+// updateGenerator handles keys in the generator overlay. Nothing changes in
+// the pattern until enter.
+func (s *sequencerModel) updateGenerator(msg tea.KeyMsg) {
+	e := s.generating
+	switch msg.String() {
+	case keyLeft, "h":
+		e.field = (e.field + numGeneratorFields - 1) % numGeneratorFields
+	case keyRight, "l", "tab":
+		e.field = (e.field + 1) % numGeneratorFields
+	case keyUp, "k", "+", "=":
+		e.change(1)
+	case keyDown, "j", "-", "_":
+		e.change(-1)
+	case "r":
+		e.seed = newSeed()
+	case "enter":
+		if len(e.pool()) == 0 {
+			s.message = fmt.Sprintf("No notes of %s between %s and %s", e.key.name(), midiNoteToName(e.low), midiNoteToName(e.high))
+			break
+		}
+		if e.wholePattern {
+			for ch := range numChannels {
+				e.fill(&s.pattern, ch)
+			}
+			s.message = fmt.Sprintf("Generated the pattern (seed %d)", e.seed)
+		} else {
+			e.fill(&s.pattern, s.cursorY)
+			s.message = fmt.Sprintf("Generated channel %d (seed %d)", s.cursorY+1, e.seed)
+		}
+		s.generating = nil
+	case "esc", "q", "G":
+		s.generating = nil
+	}
+}
+
+func (m model) viewGenerator() string {
+	s := m.sequencer
+	e := s.generating
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Generate") + "\n\n")
+
+	scope := fmt.Sprintf("channel %d", s.cursorY+1)
+	if e.wholePattern {
+		scope = "every channel"
+	}
+	values := [numGeneratorFields]string{
+		fmt.Sprintf("Density: %d%%", e.density),
+		"Rhythm: " + rhythmStyleNames[e.style],
+		"Lowest: " + midiNoteToName(e.low),
+		"Highest: " + midiNoteToName(e.high),
+		"Fill: " + scope,
+	}
+	for field, value := range values {
+		if generatorField(field) == e.field {
+			b.WriteString(selectedStyle.Render("> "+value) + "   ")
+		} else {
+			b.WriteString("  " + value + "   ")
+		}
+	}
+	fmt.Fprintf(&b, "\n\nScale: %s (set with K) • Seed: %d\n\n", e.key.name(), e.seed)
+
+	// Preview the cursor's channel as it would be generated
+	if len(e.pool()) == 0 {
+		fmt.Fprintf(&b, "%-10s%s\n", "Preview", errorStyle.Render("no notes in range"))
+		b.WriteString("\n" + helpStyle.Render("←/→: choose setting • ↑/↓: change • r: new seed • esc: cancel"))
+		return b.String()
+	}
+	var preview pattern
+	e.fill(&preview, s.cursorY)
+	fmt.Fprintf(&b, "%-10s", "Preview")
+	var notes []string
+	for step, on := range preview.steps[s.cursorY] {
+		cellStyle := lipgloss.NewStyle().Width(3).Align(lipgloss.Center).Foreground(lipgloss.Color("#666666"))
+		cell := "·"
+		if on {
+			cell = "●"
+			cellStyle = cellStyle.Foreground(velocityColor(uint8(preview.velocities[s.cursorY][step]))) //nolint:gosec // generated velocities are at most accentVelocity
+			notes = append(notes, midiNoteToName(preview.notes[s.cursorY][step]))
+		}
+		b.WriteString(cellStyle.Render(cell))
+	}
+	fmt.Fprintf(&b, "\n%-10s%s\n", "Notes", strings.Join(notes, " "))
+
+	b.WriteString("\n" + helpStyle.Render("←/→: choose setting • ↑/↓: change • r: new seed • enter: generate • esc: cancel"))
+
+	return b.String()
+}
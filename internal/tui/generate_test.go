@@ -0,0 +1,167 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseNoteName(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want int
+	}{
+		{"C4", 60},
+		{"c3", 48},
+		{"F#-1", 6},
+		{"Bb2", 46},
+		{"G9", 127},
+		{"64", 64},
+	} {
+		if got, err := parseNoteName(tt.name); err != nil || got != tt.want {
+			t.Errorf("Expected %q to be %d, got %d (%v)", tt.name, tt.want, got, err)
+		}
+	}
+	for _, bad := range []string{"", "H2", "C", "G#9", "128", "C#x"} {
+		if _, err := parseNoteName(bad); err == nil {
+			t.Errorf("Expected %q to be refused", bad)
+		}
+	}
+	if root, err := parseRoot("Cb"); err != nil || root != 11 {
+		t.Errorf("Expected Cb to be B, got %d (%v)", root, err)
+	}
+}
+
+func TestGeneratorFollowsConstraints(t *testing.T) {
+	g := generator{
+		density: 50,
+		key:     scaleLock{root: 2, scale: scaleIndexOf(t, "dorian")},
+		low:     50,
+		high:    62,
+		style:   styleStraight,
+		seed:    7,
+	}
+	var p pattern
+	g.fill(&p, 1)
+
+	hits := 0
+	for step, on := range p.steps[1] {
+		if !on {
+			continue
+		}
+		hits++
+		if note := p.notes[1][step]; note < g.low || note > g.high || !g.key.inScale(note) {
+			t.Errorf("Expected step %d in D dorian between D3 and D4, got %s", step, midiNoteToName(note))
+		}
+	}
+	if hits != 8 {
+		t.Errorf("Expected half the steps to play, got %d", hits)
+	}
+	// Straight rhythms fill the beats before anything else
+	for step := 0; step < numSteps; step += 4 {
+		if !p.steps[1][step] {
+			t.Errorf("Expected beat %d to play", step)
+		}
+	}
+
+	var again pattern
+	for ch := range numChannels {
+		g.fill(&again, ch)
+	}
+	if again.steps[1] != p.steps[1] || again.notes[1] != p.notes[1] {
+		t.Error("Expected the same seed to give the same channel, alone or in a whole pattern")
+	}
+	g.seed = 8
+	g.fill(&again, 1)
+	if again.steps[1] == p.steps[1] && again.notes[1] == p.notes[1] {
+		t.Error("Expected another seed to give another channel")
+	}
+}
+
+func scaleIndexOf(t *testing.T, name string) int {
+	t.Helper()
+	i, err := scaleIndex(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+func TestGenerateWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	opts := GenerateOptions{Density: 25, Root: "A", Scale: "minor pentatonic", Low: "A2", High: "A4", Style: "euclidean", Seed: 42, BPM: 96}
+	seed, err := Generate(filepath.Join(dir, "gen.mid"), opts)
+	if err != nil || seed != 42 {
+		t.Fatalf("Error generating: %v (seed %d)", err, seed)
+	}
+
+	s := &sequencerModel{}
+	if err := s.loadMIDI(filepath.Join(dir, "gen.mid")); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if s.bpm != 96 || s.key.name() != "A minor pentatonic" {
+		t.Errorf("Expected 96 BPM in A minor pentatonic, got %d in %s", s.bpm, s.key.name())
+	}
+	for ch := range numChannels {
+		if hits := len(slices.DeleteFunc(s.steps[ch][:], func(on bool) bool { return !on })); hits != 4 {
+			t.Errorf("Expected 4 hits on channel %d, got %d", ch+1, hits)
+		}
+	}
+
+	opts.Scale = "custom"
+	if _, err := Generate(filepath.Join(dir, "bad.mid"), opts); err == nil {
+		t.Error("Expected a custom scale to be refused without its degrees")
+	}
+}
+
+func TestGeneratorRangeWithoutScaleNotes(t *testing.T) {
+	opts := GenerateOptions{Density: 50, Root: "C", Scale: "major", Low: "C#3", High: "C#3", Style: "straight", BPM: 120}
+	if _, err := Generate(filepath.Join(t.TempDir(), "gen.mid"), opts); err == nil || !strings.Contains(err.Error(), "no notes") {
+		t.Errorf("Expected a range with no notes of the scale to be refused, got %v", err)
+	}
+
+	// The overlay says so rather than previewing or generating
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "gen.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	m.sequencer.key = scaleLock{scale: scaleIndexOf(t, "major")}
+	m = pressKeys(m, "G")
+	m.sequencer.generating.low, m.sequencer.generating.high = 49, 49
+	if view := m.viewGenerator(); !strings.Contains(view, "no notes in range") {
+		t.Errorf("Expected the preview to say there are no notes, got %q", view)
+	}
+	m = pressKeys(m, "enter")
+	if m.sequencer.generating == nil || m.sequencer.dirty {
+		t.Errorf("Expected enter to leave the pattern alone, got %q", m.sequencer.message)
+	}
+}
+
+func TestGeneratorKeys(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "gen.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	m.sequencer.cursorY = 2
+
+	// Full density on the cursor's channel only
+	m = pressKeys(m, "G", "k", "k", "k", "k", "k", "k", "k", "k", "k", "k", "enter")
+	s := &m.sequencer
+	if s.generating != nil || !s.dirty {
+		t.Fatal("Expected enter to generate and close the overlay")
+	}
+	if slices.Contains(s.steps[2][:], false) {
+		t.Errorf("Expected every step of channel 3 to play, got %v", s.steps[2])
+	}
+	if slices.Contains(s.steps[0][:], true) {
+		t.Error("Expected the other channels to be left alone")
+	}
+
+	m = pressKeys(m, "u")
+	if slices.Contains(m.sequencer.steps[2][:], true) {
+		t.Error("Expected undo to take the generated channel back")
+	}
+}
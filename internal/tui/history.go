@@ -0,0 +1,131 @@
+package tui
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/icco/genidi/internal/arp"
+)
+
+// maxUndo is how many edits each file can undo.
+const maxUndo = 100
+
+// snapshot is the editable state of a project as it stood before an edit.
+// Only the pattern in one slot is kept, since an edit only ever touches the
+// pattern on screen.
+type snapshot struct {
+	slot          int
+	pattern       pattern
+	song          []songEntry
+	songMode      bool
+	bpm           int
+	swing         int
+	mix           [numChannels]channelMix
+	exportAudible bool
+	key           scaleLock
+	seed          uint64
+	exportBars    int
+	arps          [numChannels]arp.Settings
+}
+
+// editHistory holds one file's undo and redo stacks, newest last.
+type editHistory struct {
+	undo []snapshot
+	redo []snapshot
+}
+
+// snapshot captures the project with the pattern in the given slot.
+func (s *sequencerModel) snapshot(slot int) snapshot {
+	return snapshot{
+		slot:          slot,
+		pattern:       s.patternAt(slot),
+		song:          slices.Clone(s.song),
+		songMode:      s.songMode,
+		bpm:           s.bpm,
+		swing:         s.swing,
+		mix:           s.mix,
+		exportAudible: s.exportAudible,
+		key:           s.key,
+		seed:          s.seed,
+		exportBars:    s.exportBars,
+		arps:          s.arps,
+	}
+}
+
+// history returns the edit history of the open file. Histories are kept per
+// path for the whole session, so going back to the file browser and opening
+// the file again keeps its undo stack.
+func (s *sequencerModel) history() *editHistory {
+	if s.histories == nil {
+		s.histories = make(map[string]*editHistory)
+	}
+	h, ok := s.histories[s.filePath]
+	if !ok {
+		h = &editHistory{}
+		s.histories[s.filePath] = h
+	}
+	return h
+}
+
+// changedSince reports whether anything was edited since the snapshot was
+// taken. Switching patterns alone is not an edit.
+func (s *sequencerModel) changedSince(before snapshot) bool {
+	return !reflect.DeepEqual(before, s.snapshot(before.slot))
+}
+
+// push records the state from before an edit and drops the redo stack.
+func (h *editHistory) push(before snapshot) {
+	h.undo = append(h.undo, before)
+	if len(h.undo) > maxUndo {
+		h.undo = h.undo[len(h.undo)-maxUndo:]
+	}
+	h.redo = nil
+}
+
+// undo restores the state before the last edit.
+func (s *sequencerModel) undo() {
+	h := s.history()
+	if len(h.undo) == 0 {
+		s.message = "Nothing to undo"
+		return
+	}
+	snap := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, s.snapshot(snap.slot))
+	s.restore(snap)
+	s.message = fmt.Sprintf("Undo (%d left)", len(h.undo))
+}
+
+// redo reapplies the last undone edit.
+func (s *sequencerModel) redo() {
+	h := s.history()
+	if len(h.redo) == 0 {
+		s.message = "Nothing to redo"
+		return
+	}
+	snap := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, s.snapshot(snap.slot))
+	s.restore(snap)
+	s.message = fmt.Sprintf("Redo (%d left)", len(h.redo))
+}
+
+// restore puts a snapshot back, switching to its pattern so the change is
+// visible.
+func (s *sequencerModel) restore(snap snapshot) {
+	s.cued = false
+	s.selectPattern(snap.slot)
+	s.pattern = snap.pattern
+	s.song = slices.Clone(snap.song)
+	s.songMode = snap.songMode
+	s.bpm = snap.bpm
+	s.swing = snap.swing
+	s.mix = snap.mix
+	s.exportAudible = snap.exportAudible
+	s.key = snap.key
+	s.seed = snap.seed
+	s.exportBars = snap.exportBars
+	s.arps = snap.arps
+	s.sendVolumes()
+}
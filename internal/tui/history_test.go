@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pressKeys sends key presses to the sequencer as the user would type them.
+func pressKeys(m model, keys ...string) model {
+	for _, key := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		switch key {
+		case " ":
+			msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(key)}
+		case "ctrl+r":
+			msg = tea.KeyMsg{Type: tea.KeyCtrlR}
+		case "ctrl+v":
+			msg = tea.KeyMsg{Type: tea.KeyCtrlV}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "backspace":
+			msg = tea.KeyMsg{Type: tea.KeyBackspace}
+		}
+		next, _ := m.updateSequencer(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+func TestUndoRedo(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "undo.mid")
+
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	m = pressKeys(m, " ", "l", " ", "+", "c")
+	s := &m.sequencer
+	if s.steps[0][0] || s.steps[0][1] || s.bpm != 125 {
+		t.Fatalf("Expected a cleared channel at 125 BPM, got %v %v %d", s.steps[0][0], s.steps[0][1], s.bpm)
+	}
+
+	// Moving the cursor is not an edit, so two undos bring back the tempo
+	// change and the cleared steps
+	m = pressKeys(m, "u")
+	s = &m.sequencer
+	if !s.steps[0][0] || !s.steps[0][1] {
+		t.Error("Expected undo to bring back the cleared channel")
+	}
+	m = pressKeys(m, "u")
+	s = &m.sequencer
+	if s.bpm != 120 {
+		t.Errorf("Expected undo to restore 120 BPM, got %d", s.bpm)
+	}
+
+	m = pressKeys(m, "ctrl+r", "ctrl+r")
+	s = &m.sequencer
+	if s.steps[0][0] || s.bpm != 125 {
+		t.Error("Expected redo to clear the channel again at 125 BPM")
+	}
+
+	// The history survives reopening the file in the same session
+	if err := m.sequencer.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	m = pressKeys(m, "u")
+	if s := &m.sequencer; !s.steps[0][0] || !s.steps[0][1] {
+		t.Error("Expected undo to work after reopening the file")
+	}
+
+	// A new edit drops the redo stack
+	m = pressKeys(m, "w")
+	if h := m.sequencer.history(); len(h.redo) != 0 {
+		t.Errorf("Expected an edit to clear redo, got %d entries", len(h.redo))
+	}
+}
+
+func TestUndoIsBounded(t *testing.T) {
+	s := &sequencerModel{}
+	s.resetBank()
+	for i := 0; i < maxUndo+10; i++ {
+		s.history().push(s.snapshot(s.slot))
+		s.notes[0][0]++
+	}
+	if got := len(s.history().undo); got != maxUndo {
+		t.Errorf("Expected %d undo steps, got %d", maxUndo, got)
+	}
+}
@@ -0,0 +1,411 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	numLanes         = 4   // CC lanes per channel
+	maxController    = 119 // Controllers above are channel mode messages
+	maxLaneValue     = 127
+	defaultLaneValue = 64
+	laneIncrement    = 8
+)
+
+// lockColor marks parameter locks in the lane view.
+var lockColor = lipgloss.Color("#FF66CC")
+
+// controllerNames names the controllers most often automated. Tab in the
+// lane view steps through them.
+var controllerNames = map[int]string{
+	1:  "mod wheel",
+	2:  "breath",
+	5:  "portamento",
+	7:  "volume",
+	10: "pan",
+	11: "expression",
+	64: "sustain",
+	71: "resonance",
+	72: "release",
+	73: "attack",
+	74: "cutoff",
+	91: "reverb",
+	93: "chorus",
+}
+
+// laneStep is one step of a CC lane.
+type laneStep struct {
+	value  int
+	set    bool // Whether the step has its own value rather than the lane's base
+	locked bool // Whether the value is a parameter lock, sent only with the step's note
+}
+
+// ccLane automates one controller on a channel. Every step sends its value,
+// or the lane's base value if it has none of its own, so playback picks up
+// the right value wherever it starts or loops.
+type ccLane struct {
+	controller int // CC number, zero when the lane is unused
+	base       int // Value of steps without their own
+	steps      [numSteps]laneStep
+}
+
+// value is what a step sends as it starts. A parameter lock only replaces it
+// when the step's note plays, so otherwise the step sends the base.
+func (l *ccLane) value(step int) int {
+	if st := l.steps[step]; st.set && !st.locked {
+		return st.value
+	}
+	return l.base
+}
+
+// controllerName describes a controller by number and, if it has one, name.
+func controllerName(controller int) string {
+	if controller == 0 {
+		return "off"
+	}
+	if name, ok := controllerNames[controller]; ok {
+		return fmt.Sprintf("CC%d %s", controller, name)
+	}
+	return fmt.Sprintf("CC%d", controller)
+}
+
+// lockHeld reports whether a step is held by a tie from a step with a
+// parameter lock on the lane. A lock lasts for the whole of its note.
+func (s *sequencerModel) lockHeld(ch, lane, step int) bool {
+	if !s.isTieContinuation(ch, step) {
+		return false
+	}
+	for step > 0 && s.isTieContinuation(ch, step) {
+		step--
+	}
+	return s.lanes[ch][lane].steps[step].locked
+}
+
+// laneEvents renders a channel's CC lanes into control changes. Each step's
+// values go out as the step starts, or with its note if that is nudged
+// earlier. Parameter locks follow, carrying their step's trig so they are
+// only sent when the note plays.
+func (s *sequencerModel) laneEvents(ch int) []noteEvent {
+	var events []noteEvent
+	channel := uint8(ch) //nolint:gosec // ch is bounded by numChannels constant
+	for step := range numSteps {
+		tick := s.stepPosition(step)
+		starts := s.startsNote(ch, step)
+		if starts {
+			tick = min(tick, s.noteStart(ch, step))
+		}
+		trig, rule := s.trig(ch, step)
+		for l := range s.lanes[ch] {
+			lane := &s.lanes[ch][l]
+			if lane.controller == 0 || s.lockHeld(ch, l, step) {
+				continue
+			}
+			controller := uint8(lane.controller)                                                                                                 //nolint:gosec // controller is bounded by maxController
+			events = append(events, noteEvent{tick: tick, channel: channel, note: controller, velocity: uint8(lane.value(step)), control: true}) //nolint:gosec // values are bounded by maxLaneValue
+			if st := lane.steps[step]; starts && st.locked {
+				events = append(events, noteEvent{tick: s.noteStart(ch, step), channel: channel, note: controller, velocity: uint8(st.value), control: true, trig: trig, rule: rule}) //nolint:gosec // values are bounded by maxLaneValue
+			}
+		}
+	}
+	return events
+}
+
+// lockLabel lists a step's parameter locks for the status line, or nothing
+// if it has none.
+func (s *sequencerModel) lockLabel(ch, step int) string {
+	var parts []string
+	for _, lane := range s.lanes[ch] {
+		if st := lane.steps[step]; lane.controller != 0 && st.locked {
+			parts = append(parts, fmt.Sprintf("CC%d=%d", lane.controller, st.value))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " lock " + strings.Join(parts, " ")
+}
+
+// importedControl is a control change read from an SMF track.
+type importedControl struct {
+	tick       uint32
+	controller uint8
+	value      uint8
+}
+
+// placeControls writes a track's control changes into the channel's CC
+// lanes, one lane per controller in the order they first appear, and returns
+// how many bars they span. A controller holds its value until the next
+// change, so steps in between take the value held; each bar's most common
+// value becomes the lane's base. Controls must be in order of their tick.
+func (s *sequencerModel) placeControls(ch int, controls []importedControl) int {
+	var controllers []uint8
+	bars := 0
+	for _, c := range controls {
+		if c.controller == 0 || c.controller > maxController || c.tick >= numPatterns*patternTicks {
+			continue
+		}
+		if !slices.Contains(controllers, c.controller) && len(controllers) < numLanes {
+			controllers = append(controllers, c.controller)
+		}
+		bars = max(bars, int(c.tick/patternTicks)+1)
+	}
+
+	for l, controller := range controllers {
+		// The value each step ends up with, across every bar
+		values := make([]int, bars*numSteps)
+		sent := make([]bool, len(values))
+		first := -1
+		for _, c := range controls {
+			if c.controller != controller || c.tick >= numPatterns*patternTicks {
+				continue
+			}
+			step, _ := s.nearestStep(c.tick % patternTicks)
+			i := int(c.tick/patternTicks)*numSteps + step
+			values[i], sent[i] = int(c.value), true
+			if first < 0 {
+				first = int(c.value)
+			}
+		}
+		held := first
+		for i := range values {
+			if sent[i] {
+				held = values[i]
+			}
+			values[i] = held
+		}
+
+		for bar := range bars {
+			s.selectPattern(bar)
+			lane := &s.lanes[ch][l]
+			lane.controller = int(controller)
+			lane.base = mostCommon(values[bar*numSteps : (bar+1)*numSteps])
+			for step, v := range values[bar*numSteps : (bar+1)*numSteps] {
+				lane.steps[step] = laneStep{value: v, set: v != lane.base}
+			}
+		}
+	}
+	return bars
+}
+
+// mostCommon returns the value that appears most often, the lowest on a tie.
+func mostCommon(values []int) int {
+	counts := make(map[int]int)
+	best := 0
+	for _, v := range values {
+		counts[v]++
+	}
+	for v, n := range counts {
+		if n > counts[best] || (n == counts[best] && v < best) {
+			best = v
+		}
+	}
+	return best
+}
+
+// laneEditor is the lane view of the cursor's channel. Edits apply straight
+// away, each one undoable.
+type laneEditor struct {
+	lane int // Highlighted lane
+	step int // Highlighted step, or -1 for the lane's base value
+}
+
+// openLanes shows the lanes of the cursor's channel, starting at its step.
+func (s *sequencerModel) openLanes() {
+	s.laneEdit = &laneEditor{step: s.cursorX}
+}
+
+// changeLaneValue moves the highlighted value by delta. A step without its
+// own value starts from the base.
+func (s *sequencerModel) changeLaneValue(delta int) {
+	e := s.laneEdit
+	lane := &s.lanes[s.cursorY][e.lane]
+	if lane.controller == 0 {
+		s.message = "Pick a controller with </> or tab first"
+		return
+	}
+	if e.step < 0 {
+		lane.base = min(max(lane.base+delta, 0), maxLaneValue)
+		return
+	}
+	st := &lane.steps[e.step]
+	if !st.set {
+		st.value, st.set = lane.base, true
+	}
+	st.value = min(max(st.value+delta, 0), maxLaneValue)
+}
+
+// changeController moves the highlighted lane to another controller. A lane
+// that was off starts from the middle value.
+func (s *sequencerModel) changeController(controller int) {
+	lane := &s.lanes[s.cursorY][s.laneEdit.lane]
+	if *lane == (ccLane{}) {
+		lane.base = defaultLaneValue
+	}
+	lane.controller = (controller + maxController + 1) % (maxController + 1)
+}
+
+// nextNamedController is the next controller after the given one that has
+// a name, wrapping round to off.
+func nextNamedController(controller int) int {
+	for c := controller + 1; c <= maxController; c++ {
+		if _, ok := controllerNames[c]; ok {
+			return c
+		}
+	}
+	return 0
+}
+
+// updateLanes handles keys in the lane view.
+func (s *sequencerModel) updateLanes(msg tea.KeyMsg) {
+	e := s.laneEdit
+	lane := &s.lanes[s.cursorY][e.lane]
+	switch msg.String() {
+	case keyLeft, "h":
+		e.step = max(e.step-1, -1)
+	case keyRight, "l":
+		e.step = min(e.step+1, numSteps-1)
+	case keyUp, "k":
+		e.lane = (e.lane + numLanes - 1) % numLanes
+	case keyDown, "j":
+		e.lane = (e.lane + 1) % numLanes
+	case "+", "=":
+		s.changeLaneValue(1)
+	case "-", "_":
+		s.changeLaneValue(-1)
+	case "]":
+		s.changeLaneValue(laneIncrement)
+	case "[":
+		s.changeLaneValue(-laneIncrement)
+	case ">":
+		s.changeController(lane.controller + 1)
+	case "<":
+		s.changeController(lane.controller - 1)
+	case "tab":
+		s.changeController(nextNamedController(lane.controller))
+	case " ":
+		// Give the step its own value, or hand it back to the base
+		if e.step >= 0 {
+			st := &lane.steps[e.step]
+			if st.set {
+				*st = laneStep{}
+			} else if lane.controller != 0 {
+				*st = laneStep{value: lane.base, set: true}
+			}
+		}
+	case "p":
+		// Turn the step's value into a parameter lock, or back again
+		if e.step >= 0 && lane.controller != 0 {
+			st := &lane.steps[e.step]
+			if !st.set {
+				st.value, st.set = lane.base, true
+			}
+			st.locked = !st.locked
+		}
+	case "x":
+		lane.steps = [numSteps]laneStep{}
+		s.message = fmt.Sprintf("Cleared lane %d", e.lane+1)
+	case "esc", "q", "V":
+		s.laneEdit = nil
+	}
+}
+
+func (m model) viewLanes() string {
+	s := m.sequencer
+	e := s.laneEdit
+	ch := s.cursorY
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(fmt.Sprintf("CC Lanes: Channel %d", ch+1)) + "\n\n")
+
+	cellStyle := lipgloss.NewStyle().Width(4).Align(lipgloss.Right)
+	cursorStyle := cellStyle.Background(lipgloss.Color("#5A3DBF"))
+	dimStyle := cellStyle.Foreground(lipgloss.Color("#666666"))
+
+	// Step numbers, then the channel's notes so locks can be lined up with them
+	fmt.Fprintf(&b, "%-20s%5s ", "", "Base")
+	for step := range numSteps {
+		b.WriteString(dimStyle.Render(fmt.Sprintf("%X", step)))
+	}
+	fmt.Fprintf(&b, "\n%-20s%5s ", "Notes", "")
+	for step := range numSteps {
+		switch {
+		case s.isTieContinuation(ch, step):
+			b.WriteString(cellStyle.Render("━"))
+		case s.steps[ch][step]:
+			b.WriteString(cellStyle.Foreground(velocityColor(s.velocity(ch, step))).Render("●"))
+		default:
+			b.WriteString(dimStyle.Render("·"))
+		}
+	}
+	b.WriteString("\n")
+
+	for l := range s.lanes[ch] {
+		lane := &s.lanes[ch][l]
+		label := fmt.Sprintf("%d %s", l+1, controllerName(lane.controller))
+		if l == e.lane {
+			b.WriteString(selectedStyle.Render(fmt.Sprintf("%-20s", label)))
+		} else {
+			fmt.Fprintf(&b, "%-20s", label)
+		}
+
+		base := "-"
+		if lane.controller != 0 {
+			base = fmt.Sprintf("%d", lane.base)
+		}
+		style := cellStyle.Width(5)
+		if l == e.lane && e.step < 0 {
+			style = style.Background(lipgloss.Color("#5A3DBF"))
+		}
+		b.WriteString(style.Render(base) + " ")
+
+		for step, st := range lane.steps {
+			style, text := dimStyle, "·"
+			switch {
+			case lane.controller == 0:
+			case st.locked:
+				style, text = cellStyle.Foreground(lockColor), fmt.Sprintf("%d", st.value)
+			case st.set:
+				style, text = cellStyle, fmt.Sprintf("%d", st.value)
+			}
+			if l == e.lane && step == e.step {
+				style = style.Inherit(cursorStyle)
+			}
+			b.WriteString(style.Render(text))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	lane := &s.lanes[ch][e.lane]
+	switch {
+	case lane.controller == 0:
+		b.WriteString("This lane is off: pick a controller with </> or tab.\n")
+	case e.step < 0:
+		fmt.Fprintf(&b, "%s: steps without their own value send %d.\n", controllerName(lane.controller), lane.base)
+	default:
+		st := lane.steps[e.step]
+		switch {
+		case st.locked && !s.startsNote(ch, e.step):
+			fmt.Fprintf(&b, "Step %X: lock %d, but no note starts here, so it sends the base %d.\n", e.step, st.value, lane.base)
+		case st.locked:
+			fmt.Fprintf(&b, "Step %X: sends %d, or lock %d whenever its note plays.\n", e.step, lane.base, st.value)
+		default:
+			fmt.Fprintf(&b, "Step %X: sends %d.\n", e.step, lane.value(e.step))
+		}
+	}
+	if s.message != "" {
+		b.WriteString(errorStyle.Render(s.message) + "\n")
+	}
+
+	b.WriteString("\n" + helpStyle.Render("←/→: step (left of step 0: base) • ↑/↓: lane • +/-: value • [/]: value by 8"))
+	b.WriteString("\n" + helpStyle.Render("space: own value/base • p: parameter lock (sent with the note) • </>: controller • tab: next named controller"))
+	b.WriteString("\n" + helpStyle.Render("x: clear lane • esc: close"))
+
+	return b.String()
+}
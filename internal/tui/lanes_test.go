@@ -0,0 +1,182 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// cutoffLane is a CC74 lane resting at 64, raised to 100 on step 2 and
+// locked to 20 on step 4.
+func cutoffLane() ccLane {
+	lane := ccLane{controller: 74, base: 64}
+	lane.steps[2] = laneStep{value: 100, set: true}
+	lane.steps[4] = laneStep{value: 20, set: true, locked: true}
+	return lane
+}
+
+func TestLaneEvents(t *testing.T) {
+	s := &sequencerModel{}
+	s.resetBank()
+	s.lanes[0][1] = cutoffLane()
+
+	var values []uint8
+	for _, ev := range s.channelEvents(0) {
+		values = append(values, ev.velocity)
+	}
+	// The lock has no note to go with, so step 4 sends the base
+	want := []uint8{64, 64, 100, 64, 64, 64, 64, 64, 64, 64, 64, 64, 64, 64, 64, 64}
+	if !slices.Equal(values, want) {
+		t.Errorf("Expected %v, got %v", want, values)
+	}
+
+	// A nudged, half-chance note on step 4 hears its lock first, and only
+	// when it plays
+	s.steps[0][4] = true
+	s.nudges[0][4] = -10
+	s.chances[0][4] = 50
+	var at4 []noteEvent
+	for _, ev := range s.channelEvents(0) {
+		if ev.tick == s.noteStart(0, 4) {
+			at4 = append(at4, ev)
+		}
+	}
+	if len(at4) != 3 || at4[0].velocity != 64 || at4[1].velocity != 20 || at4[2].control {
+		t.Fatalf("Expected the base, the lock and then the note, got %+v", at4)
+	}
+	if at4[0].trig != 0 || at4[1].trig != trigID(0, 4) {
+		t.Error("Expected the lock, and only the lock, to follow the step's chance")
+	}
+
+	// Tied on, the lock holds until the note ends
+	s.ties[0][4] = true
+	s.steps[0][5] = true
+	for _, ev := range s.channelEvents(0) {
+		if ev.control && ev.tick == s.stepPosition(5) {
+			t.Errorf("Expected the lock to hold through the tie, got %+v", ev)
+		}
+	}
+}
+
+func TestLoopKeepsLaneEventsInside(t *testing.T) {
+	s := &sequencerModel{}
+	s.resetBank()
+	s.lanes[0][0] = ccLane{controller: 10}
+	state := playbackState{loopStart: 4 * ticksPerStep, loopEnd: 8 * ticksPerStep}
+	kept := state.loopEvents(s.channelEvents(0))
+	if len(kept) != 4 || kept[0].tick != 4*ticksPerStep {
+		t.Errorf("Expected the lane's steps 4-7, got %+v", kept)
+	}
+
+	// A control change of zero is not a note off to carry into the next pass
+	p := &scheduler{state: state}
+	p.state.patterns = map[int][]noteEvent{0: kept}
+	p.advance(p.state, state.loopEnd)
+	if len(p.carry) != 0 {
+		t.Errorf("Expected nothing carried over, got %+v", p.carry)
+	}
+}
+
+func TestLanesRoundTrip(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "lanes.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.steps[2][4] = true
+	s.lanes[2][0] = cutoffLane()
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	rd, err := smf.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Error reading MIDI: %v", err)
+	}
+	var values []uint8
+	for _, ev := range rd.Tracks[3] {
+		var ch, controller, value uint8
+		if ev.Message.GetControlChange(&ch, &controller, &value) && controller == 74 {
+			values = append(values, value)
+		}
+	}
+	if len(values) != numSteps+1 || values[2] != 100 || values[5] != 20 {
+		t.Errorf("Expected a CC74 per step plus the lock, got %v", values)
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if got := s2.lanes[2][0]; got != cutoffLane() {
+		t.Errorf("Expected the lane to be restored, got %+v", got)
+	}
+}
+
+func TestImportControlsIntoLanes(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "sweep.mid")
+
+	// A plain file panning right on step 4 and back on step 8
+	sm := smf.New()
+	sm.TimeFormat = smf.MetricTicks(ticksPerQuarterNote)
+	var track0, track1 smf.Track
+	track0.Add(0, smf.MetaTempo(120))
+	track0.Close(0)
+	track1.Add(0, midi.ControlChange(0, 10, 30))
+	track1.Add(4*ticksPerStep, midi.ControlChange(0, 10, 110))
+	track1.Add(4*ticksPerStep, midi.ControlChange(0, 10, 30))
+	track1.Close(0)
+	if err := sm.Add(track0); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Add(track1); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.WriteFile(testPath); err != nil {
+		t.Fatalf("Error writing MIDI: %v", err)
+	}
+
+	s := &sequencerModel{}
+	if err := s.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	lane := s.lanes[0][0]
+	if lane.controller != 10 || lane.base != 30 {
+		t.Fatalf("Expected a pan lane resting at 30, got %+v", lane)
+	}
+	for step := range numSteps {
+		if want := step >= 4 && step < 8; lane.steps[step].set != want || (want && lane.steps[step].value != 110) {
+			t.Errorf("Expected step %X to hold 110: %v, got %+v", step, want, lane.steps[step])
+		}
+	}
+}
+
+func TestLaneKeys(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "lanes.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	// CC1 on the first lane, step 0 raised by 9, then step 1 locked
+	m = pressKeys(m, "V", "tab", "+", "]", "right", "p")
+	lane := m.sequencer.lanes[0][0]
+	if lane.controller != 1 || lane.steps[0] != (laneStep{value: 73, set: true}) || !lane.steps[1].locked {
+		t.Fatalf("Expected CC1 with step 0 at 73 and a lock on step 1, got %+v", lane)
+	}
+
+	// The base sits left of step 0
+	m = pressKeys(m, "left", "left", "-", "esc")
+	if got := m.sequencer.lanes[0][0].base; got != defaultLaneValue-1 || m.sequencer.laneEdit != nil {
+		t.Errorf("Expected the base lowered to %d and the view closed, got %d", defaultLaneValue-1, got)
+	}
+
+	m = pressKeys(m, "u")
+	if got := m.sequencer.lanes[0][0].base; got != defaultLaneValue {
+		t.Errorf("Expected undo to restore the base, got %d", got)
+	}
+}
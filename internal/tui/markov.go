@@ -0,0 +1,369 @@
+package tui
+
+import (
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// A step becomes a token: a note number for a step that starts a note, or
+// one of these.
+const (
+	tokenRest = -1 // Nothing plays
+	tokenHold = -2 // The note before is tied over
+)
+
+const (
+	minOrder     = 1
+	maxOrder     = 4
+	defaultOrder = 2
+)
+
+// markovChain learns which token follows each run of up to maxOrder tokens.
+// Contexts of every length are kept, so a run never seen at the chosen order
+// can fall back to a shorter one.
+type markovChain struct {
+	next   map[string][]int // Context to the tokens seen after it, repeated as often as they were
+	starts [][]int          // Opening tokens of each sequence
+}
+
+func newMarkovChain() *markovChain {
+	return &markovChain{next: make(map[string][]int)}
+}
+
+// contextKey joins a run of tokens into a map key.
+func contextKey(tokens []int) string {
+	parts := make([]string, len(tokens))
+	for i, tok := range tokens {
+		parts[i] = strconv.Itoa(tok)
+	}
+	return strings.Join(parts, ",")
+}
+
+// train adds a sequence of tokens to the chain.
+func (c *markovChain) train(tokens []int) {
+	if len(tokens) == 0 {
+		return
+	}
+	c.starts = append(c.starts, tokens[:min(maxOrder, len(tokens))])
+	for i, tok := range tokens {
+		for k := 0; k <= min(maxOrder, i); k++ {
+			key := contextKey(tokens[i-k : i])
+			c.next[key] = append(c.next[key], tok)
+		}
+	}
+}
+
+// empty reports whether the chain has seen nothing.
+func (c *markovChain) empty() bool {
+	return len(c.starts) == 0
+}
+
+// generate makes n tokens, each chosen from what followed the last order
+// tokens in training, or fewer tokens when that run was never seen.
+func (c *markovChain) generate(rng *rand.Rand, order, n int) []int {
+	start := c.starts[rng.IntN(len(c.starts))]
+	tokens := append([]int(nil), start[:min(order, len(start))]...)
+	for len(tokens) < n {
+		for k := min(order, len(tokens)); k >= 0; k-- {
+			if next := c.next[contextKey(tokens[len(tokens)-k:])]; len(next) > 0 {
+				tokens = append(tokens, next[rng.IntN(len(next))])
+				break
+			}
+		}
+	}
+	return tokens[:n]
+}
+
+// markovModel is a chain per channel, so bass lines and hi-hats each keep
+// their own style, and one over every channel for channels the corpus never
+// uses.
+type markovModel struct {
+	channels [numChannels]*markovChain
+	all      *markovChain
+	files    int // Files the model was trained on
+}
+
+// channelTokens turns a channel of the pattern being edited into tokens.
+func (s *sequencerModel) channelTokens(ch int) []int {
+	tokens := make([]int, numSteps)
+	for step := range tokens {
+		switch {
+		case !s.steps[ch][step]:
+			tokens[step] = tokenRest
+		case s.isTieContinuation(ch, step):
+			tokens[step] = tokenHold
+		default:
+			tokens[step] = s.notes[ch][step]
+		}
+	}
+	return tokens
+}
+
+// trainMarkov reads every MIDI file under dir and learns the notes and
+// rhythms of each channel, bar after bar through every pattern a file holds.
+// Like in the file browser, hidden files and directories are skipped, and
+// so are files that can't be read.
+func trainMarkov(dir string) (*markovModel, error) {
+	m := &markovModel{all: newMarkovChain()}
+	for ch := range m.channels {
+		m.channels[ch] = newMarkovChain()
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !isMIDIFile(d.Name()) {
+			return nil
+		}
+		rd, err := smf.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		s := &sequencerModel{}
+		s.resetBank()
+		s.resetMix()
+		s.importSMF(rd)
+		trained := false
+		for ch := range numChannels {
+			var tokens []int
+			for slot := range numPatterns {
+				if p := s.patternAt(slot); !p.isEmpty() {
+					tokens = append(tokens, s.withPattern(slot).channelTokens(ch)...)
+				}
+			}
+			if len(tokens) > 0 && !allRests(tokens) {
+				m.channels[ch].train(tokens)
+				m.all.train(tokens)
+				trained = true
+			}
+		}
+		if trained {
+			m.files++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if m.files == 0 {
+		return nil, fmt.Errorf("no MIDI files with notes in %s", dir)
+	}
+	return m, nil
+}
+
+func allRests(tokens []int) bool {
+	for _, tok := range tokens {
+		if tok != tokenRest {
+			return false
+		}
+	}
+	return true
+}
+
+// markovGenerator generates channels in the style of a trained model.
+type markovGenerator struct {
+	model *markovModel
+	order int
+	seed  uint64
+}
+
+// fill replaces a channel of p with a generated one. Like the random
+// generator, each channel has its own stream of the seed.
+func (g *markovGenerator) fill(p *pattern, ch int) {
+	rng := rand.New(rand.NewPCG(g.seed, uint64(ch))) //nolint:gosec // musical randomness, not security; ch is bounded by numChannels
+	chain := g.model.channels[ch]
+	if chain.empty() {
+		chain = g.model.all
+	}
+	p.resetChannel(ch)
+	last := -1 // Step sounding the note a hold ties on from
+	for step, tok := range chain.generate(rng, g.order, numSteps) {
+		switch {
+		case tok == tokenHold && last >= 0:
+			p.steps[ch][step] = true
+			p.notes[ch][step] = p.notes[ch][last]
+			p.ties[ch][last] = true
+			last = step
+		case tok >= 0:
+			p.steps[ch][step] = true
+			p.notes[ch][step] = tok
+			last = step
+		default:
+			last = -1
+		}
+	}
+}
+
+// MarkovOptions are the settings genidi markov generates a pattern with.
+type MarkovOptions struct {
+	Corpus string // Directory of MIDI files to learn from
+	Order  int    // How many steps back each choice looks
+	Seed   uint64 // Zero picks a seed
+	BPM    int
+}
+
+// GenerateMarkov writes a new MIDI file at path with every channel of the
+// first pattern generated in the style of the corpus, and returns the seed
+// used.
+func GenerateMarkov(path string, opts MarkovOptions) (uint64, error) {
+	if opts.Order < minOrder || opts.Order > maxOrder {
+		return 0, fmt.Errorf("order %d is not between %d and %d", opts.Order, minOrder, maxOrder)
+	}
+	if opts.BPM < minBPM || opts.BPM > maxBPM {
+		return 0, fmt.Errorf("tempo %d BPM is not between %d and %d", opts.BPM, minBPM, maxBPM)
+	}
+	model, err := trainMarkov(opts.Corpus)
+	if err != nil {
+		return 0, err
+	}
+	g := markovGenerator{model: model, order: opts.Order, seed: opts.Seed}
+	if g.seed == 0 {
+		g.seed = newSeed()
+	}
+
+	s := &sequencerModel{filePath: path, bpm: opts.BPM, swing: minSwing, seed: g.seed}
+	s.resetBank()
+	s.resetMix()
+	for ch := range numChannels {
+		g.fill(&s.pattern, ch)
+	}
+	return g.seed, s.saveMIDI()
+}
+
+// markovField is the setting highlighted in the Markov overlay.
+type markovField int
+
+const (
+	markovOrder markovField = iota
+	markovScope
+	numMarkovFields
+)
+
+// markovEditor is the Markov generator being set up in the overlay.
+type markovEditor struct {
+	markovGenerator
+	corpus       string // Directory the model was trained on
+	wholePattern bool   // Fill every channel rather than the cursor's
+	field        markovField
+}
+
+// openMarkov trains a model on the MIDI files next to the file being edited,
+// which are the ones the file browser was showing, and starts the overlay.
+func (s *sequencerModel) openMarkov() {
+	dir := filepath.Dir(s.filePath)
+	model, err := trainMarkov(dir)
+	if err != nil {
+		s.message = fmt.Sprintf("Error reading MIDI files: %v", err)
+		return
+	}
+	s.markov = &markovEditor{
+		markovGenerator: markovGenerator{model: model, order: defaultOrder, seed: newSeed()},
+		corpus:          dir,
+	}
+}
+
+// updateMarkov handles keys in the Markov overlay. Nothing changes in the
+// pattern until enter.
+func (s *sequencerModel) updateMarkov(msg tea.KeyMsg) {
+	e := s.markov
+	switch msg.String() {
+	case keyLeft, "h":
+		e.field = (e.field + numMarkovFields - 1) % numMarkovFields
+	case keyRight, "l", "tab":
+		e.field = (e.field + 1) % numMarkovFields
+	case keyUp, "k", "+", "=", keyDown, "j", "-", "_":
+		delta := 1
+		if key := msg.String(); key == keyDown || key == "j" || key == "-" || key == "_" {
+			delta = -1
+		}
+		if e.field == markovOrder {
+			e.order = min(max(e.order+delta, minOrder), maxOrder)
+		} else {
+			e.wholePattern = !e.wholePattern
+		}
+	case "r":
+		e.seed = newSeed()
+	case "enter":
+		if e.wholePattern {
+			for ch := range numChannels {
+				e.fill(&s.pattern, ch)
+			}
+			s.message = fmt.Sprintf("Generated the pattern from %d file(s) (seed %d)", e.model.files, e.seed)
+		} else {
+			e.fill(&s.pattern, s.cursorY)
+			s.message = fmt.Sprintf("Generated channel %d from %d file(s) (seed %d)", s.cursorY+1, e.model.files, e.seed)
+		}
+		s.markov = nil
+	case "esc", "q", "H":
+		s.markov = nil
+	}
+}
+
+func (m model) viewMarkov() string {
+	s := m.sequencer
+	e := s.markov
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Generate from MIDI Files") + "\n\n")
+	fmt.Fprintf(&b, "Learned from %d file(s) in %s\n\n", e.model.files, e.corpus)
+
+	scope := fmt.Sprintf("channel %d", s.cursorY+1)
+	if e.wholePattern {
+		scope = "every channel"
+	}
+	values := [numMarkovFields]string{
+		fmt.Sprintf("Order: %d", e.order),
+		"Fill: " + scope,
+	}
+	for field, value := range values {
+		if markovField(field) == e.field {
+			b.WriteString(selectedStyle.Render("> "+value) + "   ")
+		} else {
+			b.WriteString("  " + value + "   ")
+		}
+	}
+	fmt.Fprintf(&b, "\n\nSeed: %d\n\n", e.seed)
+
+	// Preview the cursor's channel as it would be generated
+	preview := &sequencerModel{}
+	e.fill(&preview.pattern, s.cursorY)
+	fmt.Fprintf(&b, "%-10s", "Preview")
+	var notes []string
+	for step, on := range preview.steps[s.cursorY] {
+		cellStyle := lipgloss.NewStyle().Width(3).Align(lipgloss.Center).Foreground(lipgloss.Color("#666666"))
+		cell := "·"
+		switch {
+		case preview.isTieContinuation(s.cursorY, step):
+			cell = "━"
+			cellStyle = cellStyle.Foreground(velocityColor(defaultVelocity))
+		case on:
+			cell = "●"
+			cellStyle = cellStyle.Foreground(velocityColor(defaultVelocity))
+			notes = append(notes, midiNoteToName(preview.notes[s.cursorY][step]))
+		}
+		b.WriteString(cellStyle.Render(cell))
+	}
+	fmt.Fprintf(&b, "\n%-10s%s\n", "Notes", strings.Join(notes, " "))
+	b.WriteString("\nHigher orders follow the files more closely; lower orders wander further from them.\n")
+
+	b.WriteString("\n" + helpStyle.Render("←/→: choose setting • ↑/↓: change • r: new seed • enter: generate • esc: cancel"))
+
+	return b.String()
+}
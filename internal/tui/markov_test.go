@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMarkovChainFollowsCorpus(t *testing.T) {
+	// Every run of two tokens has one continuation, so order 2 repeats the riff
+	riff := []int{60, tokenHold, tokenRest, 63, 60, tokenRest, 67, tokenRest}
+	c := newMarkovChain()
+	c.train(slices.Concat(riff, riff, riff))
+
+	got := c.generate(rand.New(rand.NewPCG(1, 0)), 2, 16)
+	if want := slices.Concat(riff, riff); !slices.Equal(got, want) {
+		t.Errorf("Expected the riff twice, got %v", got)
+	}
+
+	// Order 1 still only plays what the corpus played
+	for _, tok := range c.generate(rand.New(rand.NewPCG(2, 0)), 1, 64) {
+		if !slices.Contains(riff, tok) {
+			t.Errorf("Expected only tokens from the riff, got %d", tok)
+		}
+	}
+}
+
+// writeCorpusFile saves a file whose first channel plays the given notes,
+// the first one held for two steps.
+func writeCorpusFile(t *testing.T, path string, notes ...int) {
+	t.Helper()
+	s := &sequencerModel{filePath: path, bpm: 120}
+	s.resetBank()
+	s.resetMix()
+	for i, note := range notes {
+		s.steps[0][i*4] = true
+		s.notes[0][i*4] = note
+	}
+	s.steps[0][1] = true
+	s.notes[0][1] = notes[0]
+	s.ties[0][0] = true
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+}
+
+func TestTrainMarkovOnDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeCorpusFile(t, filepath.Join(dir, "a.mid"), 48, 51, 55, 58)
+	writeCorpusFile(t, filepath.Join(dir, "b.mid"), 48, 55, 51, 58)
+	writeCorpusFile(t, filepath.Join(dir, ".hidden.mid"), 90, 91, 92, 93)
+	if err := os.WriteFile(filepath.Join(dir, "broken.mid"), []byte("not midi"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := trainMarkov(dir)
+	if err != nil {
+		t.Fatalf("Error training: %v", err)
+	}
+	if model.files != 2 {
+		t.Errorf("Expected 2 files to be learned from, got %d", model.files)
+	}
+	if !model.channels[1].empty() {
+		t.Error("Expected nothing to be learned for an unused channel")
+	}
+
+	g := markovGenerator{model: model, order: 2, seed: 3}
+	var p pattern
+	for ch := range numChannels {
+		g.fill(&p, ch)
+	}
+	s := &sequencerModel{}
+	s.pattern = p
+	for ch := range numChannels {
+		for step, on := range p.steps[ch] {
+			if on && !slices.Contains([]int{48, 51, 55, 58}, p.notes[ch][step]) {
+				t.Errorf("Expected only corpus notes, got %s on channel %d", midiNoteToName(p.notes[ch][step]), ch+1)
+			}
+		}
+	}
+	// Both files open on a held C3, so channel 1 does too
+	if !s.steps[0][0] || p.notes[0][0] != 48 || !s.isTieContinuation(0, 1) {
+		t.Errorf("Expected a held C3 to open channel 1, got %v", s.channelTokens(0)[:2])
+	}
+
+	var again pattern
+	g.fill(&again, 2)
+	if again.steps[2] != p.steps[2] || again.notes[2] != p.notes[2] {
+		t.Error("Expected the same seed to give the same channel")
+	}
+
+	if _, err := trainMarkov(t.TempDir()); err == nil {
+		t.Error("Expected an empty directory to be refused")
+	}
+}
+
+func TestGenerateMarkovWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	writeCorpusFile(t, filepath.Join(dir, "a.mid"), 60, 62, 64, 65)
+
+	out := filepath.Join(t.TempDir(), "out.mid")
+	if _, err := GenerateMarkov(out, MarkovOptions{Corpus: dir, Order: 5, BPM: 120}); err == nil {
+		t.Error("Expected order 5 to be refused")
+	}
+	seed, err := GenerateMarkov(out, MarkovOptions{Corpus: dir, Order: 3, Seed: 9, BPM: 100})
+	if err != nil || seed != 9 {
+		t.Fatalf("Error generating: %v (seed %d)", err, seed)
+	}
+	s := &sequencerModel{}
+	if err := s.loadMIDI(out); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if s.bpm != 100 || !s.steps[0][0] || s.notes[0][0] != 60 {
+		t.Errorf("Expected the corpus's opening C4 at 100 BPM, got %s at %d", midiNoteToName(s.notes[0][0]), s.bpm)
+	}
+}
+
+func TestMarkovKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeCorpusFile(t, filepath.Join(dir, "a.mid"), 60, 62, 64, 65)
+
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(dir, "new.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	m = pressKeys(m, "H")
+	if m.sequencer.markov == nil || m.sequencer.markov.model.files != 1 {
+		t.Fatal("Expected the overlay to open with the one file that has notes")
+	}
+	m = pressKeys(m, "enter")
+	s := &m.sequencer
+	if s.markov != nil || !s.steps[0][0] || s.notes[0][0] != 60 {
+		t.Errorf("Expected channel 1 to be generated from the corpus, got %v", s.channelTokens(0))
+	}
+}
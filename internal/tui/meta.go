@@ -0,0 +1,340 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/icco/genidi/internal/arp"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// seqDataID prefixes genidi's sequencer-specific meta event. 0x7D is the
+// manufacturer ID set aside for non-commercial use.
+var seqDataID = []byte{0x7D, 'g', 'e', 'n', 'i', 'd', 'i'}
+
+// projectMeta holds the settings a plain SMF has no event for. It is stored
+// as JSON in a sequencer-specific meta event on the tempo track, which other
+// MIDI software ignores.
+type projectMeta struct {
+	Swing         int                    `json:"swing,omitempty"`
+	Patterns      map[string]patternMeta `json:"patterns,omitempty"` // Non-empty bank slots by name
+	Slot          string                 `json:"slot,omitempty"`     // Slot being edited
+	Song          []songEntryMeta        `json:"song,omitempty"`
+	SongMode      bool                   `json:"songMode,omitempty"`
+	Mix           []channelMixMeta       `json:"mix,omitempty"`           // Mute, solo and volume by channel
+	ExportAudible bool                   `json:"exportAudible,omitempty"` // Whether the note tracks leave out channels that aren't heard
+	Scale         *scaleMeta             `json:"scale,omitempty"`
+	Seed          uint64                 `json:"seed,omitempty"`       // Seed for probability steps
+	ExportBars    int                    `json:"exportBars,omitempty"` // Bars of variation the note tracks hold, zero for the pattern as written
+	Arps          []arpMeta              `json:"arps,omitempty"`       // Arpeggiator by channel, when any is on
+}
+
+// scaleMeta stores the scale lock by name, with a custom scale's degrees.
+type scaleMeta struct {
+	Root   string `json:"root"`
+	Name   string `json:"name"`
+	Custom []int  `json:"custom,omitempty"` // Semitones above the root
+}
+
+type channelMixMeta struct {
+	Muted  bool `json:"muted,omitempty"`
+	Soloed bool `json:"soloed,omitempty"`
+	Volume int  `json:"volume"`
+}
+
+// arpMeta stores a channel's arpeggiator by name.
+type arpMeta struct {
+	Mode    string `json:"mode"`
+	Rate    string `json:"rate"`
+	Octaves int    `json:"octaves"`
+	Gate    int    `json:"gate"`
+}
+
+// settings reads the arpeggiator back, switching it off if the mode is
+// unknown and falling back to defaults for anything else unreadable.
+func (m arpMeta) settings() arp.Settings {
+	a := arp.Settings{Octaves: m.Octaves, Gate: m.Gate}
+	a.Mode, _ = arp.ParseMode(m.Mode)
+	a.Rate, _ = arp.ParseRate(m.Rate)
+	return a.Valid()
+}
+
+// patternMeta stores a whole pattern, since the note tracks only hold the
+// pattern or song being played.
+type patternMeta struct {
+	Steps      [numChannels][numSteps]bool   `json:"steps"`
+	Notes      [numChannels][numSteps]int    `json:"notes"`
+	Velocities [numChannels][numSteps]int    `json:"velocities"`
+	Gates      [numChannels][numSteps]int    `json:"gates"`
+	Ties       [numChannels][numSteps]bool   `json:"ties"`
+	Chords     [numChannels][numSteps][]int  `json:"chords"`
+	Nudges     [numChannels][numSteps]int    `json:"nudges"`
+	Chances    [numChannels][numSteps]int    `json:"chances"`
+	Conditions [numChannels][numSteps]string `json:"conditions"` // As "nth:every", empty for every pass
+	Ratchets   [numChannels][numSteps]int    `json:"ratchets"`
+	Lanes      []laneMeta                    `json:"lanes,omitempty"` // CC lanes in use
+}
+
+// laneMeta stores a CC lane that is in use.
+type laneMeta struct {
+	Channel    int            `json:"channel"`
+	Lane       int            `json:"lane"`
+	Controller int            `json:"controller"`
+	Base       int            `json:"base"`
+	Values     [numSteps]int  `json:"values"`
+	Set        [numSteps]bool `json:"set"`    // Steps with their own value
+	Locked     [numSteps]bool `json:"locked"` // Steps whose value is a parameter lock
+}
+
+// lane reads a CC lane back, with values clamped to the MIDI range.
+func (m laneMeta) lane() ccLane {
+	l := ccLane{controller: min(max(m.Controller, 0), maxController), base: min(max(m.Base, 0), maxLaneValue)}
+	for step := range l.steps {
+		l.steps[step] = laneStep{value: min(max(m.Values[step], 0), maxLaneValue), set: m.Set[step] || m.Locked[step], locked: m.Locked[step]}
+	}
+	return l
+}
+
+type songEntryMeta struct {
+	Slot    string `json:"slot"`
+	Repeats int    `json:"repeats"`
+}
+
+func (p *pattern) meta() patternMeta {
+	m := patternMeta{
+		Steps:      p.steps,
+		Notes:      p.notes,
+		Velocities: p.velocities,
+		Gates:      p.gates,
+		Ties:       p.ties,
+		Chords:     p.chords,
+		Nudges:     p.nudges,
+		Chances:    p.chances,
+		Ratchets:   p.ratchets,
+	}
+	for ch := range p.conditions {
+		for step, c := range p.conditions[ch] {
+			m.Conditions[ch][step] = c.String()
+		}
+	}
+	for ch := range p.lanes {
+		for l, lane := range p.lanes[ch] {
+			if lane == (ccLane{}) {
+				continue
+			}
+			lm := laneMeta{Channel: ch, Lane: l, Controller: lane.controller, Base: lane.base}
+			for step, st := range lane.steps {
+				lm.Values[step], lm.Set[step], lm.Locked[step] = st.value, st.set, st.locked
+			}
+			m.Lanes = append(m.Lanes, lm)
+		}
+	}
+	return m
+}
+
+// pattern reads a pattern back, with notes clamped to the MIDI range and
+// chord notes outside it dropped.
+func (m *patternMeta) pattern() pattern {
+	p := pattern{
+		steps:      m.Steps,
+		velocities: m.Velocities,
+		gates:      m.Gates,
+		ties:       m.Ties,
+		nudges:     m.Nudges,
+		chances:    m.Chances,
+		ratchets:   m.Ratchets,
+	}
+	for ch := range m.Notes {
+		for step, note := range m.Notes[ch] {
+			root := min(max(note, minMIDINote), maxMIDINote)
+			p.notes[ch][step] = root
+			for _, interval := range m.Chords[ch][step] {
+				if interval > 0 && root+interval <= maxMIDINote {
+					p.chords[ch][step] = append(p.chords[ch][step], interval)
+				}
+			}
+		}
+	}
+	for ch := range m.Conditions {
+		for step, c := range m.Conditions[ch] {
+			p.conditions[ch][step] = parseCondition(c)
+		}
+	}
+	for _, lm := range m.Lanes {
+		if lm.Channel >= 0 && lm.Channel < numChannels && lm.Lane >= 0 && lm.Lane < numLanes {
+			p.lanes[lm.Channel][lm.Lane] = lm.lane()
+		}
+	}
+	return p
+}
+
+// metaMessage encodes the sequencer's project settings as a meta event.
+func (s *sequencerModel) metaMessage() (smf.Message, error) {
+	meta := projectMeta{
+		Swing:         s.swing,
+		Patterns:      make(map[string]patternMeta),
+		Slot:          slotName(s.slot),
+		SongMode:      s.songMode,
+		ExportAudible: s.exportAudible,
+		Seed:          s.seed,
+		ExportBars:    s.exportBars,
+	}
+	if s.key.locked() {
+		meta.Scale = &scaleMeta{Root: noteNames[s.key.root], Name: scaleTypes[s.key.scale].name}
+		if s.key.scale == scaleCustom {
+			meta.Scale.Custom = s.key.degrees()
+		}
+	}
+	for _, mix := range s.mix {
+		meta.Mix = append(meta.Mix, channelMixMeta{Muted: mix.muted, Soloed: mix.soloed, Volume: mix.volume})
+	}
+	if slices.ContainsFunc(s.arps[:], func(a arp.Settings) bool { return a.Mode != arp.Off }) {
+		for ch := range s.arps {
+			a := s.arp(ch)
+			meta.Arps = append(meta.Arps, arpMeta{Mode: a.Mode.String(), Rate: a.Rate.String(), Octaves: a.Octaves, Gate: a.Gate})
+		}
+	}
+	for slot := 0; slot < len(s.bank); slot++ {
+		if p := s.patternAt(slot); !p.isEmpty() {
+			meta.Patterns[slotName(slot)] = p.meta()
+		}
+	}
+	for _, e := range s.song {
+		meta.Song = append(meta.Song, songEntryMeta{Slot: slotName(e.slot), Repeats: e.repeats})
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding project settings: %w", err)
+	}
+	return smf.MetaSequencerData(append(bytes.Clone(seqDataID), data...)), nil
+}
+
+// metaData returns the payload of a meta message. GetMetaSeqData assumes the
+// length fits in one byte, which garbles a bank of patterns.
+func metaData(msg smf.Message) []byte {
+	// The length is a variable-length quantity ending at the first byte
+	// without its high bit set
+	for i := 2; i < len(msg); i++ {
+		if msg[i]&0x80 == 0 {
+			return msg[i+1:]
+		}
+	}
+	return nil
+}
+
+// readMeta restores project settings from a tempo track written by
+// metaMessage. Tracks without genidi's meta event leave the defaults alone.
+// It reports whether the pattern bank was restored, in which case the note
+// tracks have nothing more to add unless matchesBank finds them edited.
+func (s *sequencerModel) readMeta(track smf.Track) bool {
+	for _, ev := range track {
+		if !ev.Message.Is(smf.MetaSeqDataMsg) {
+			continue
+		}
+		data := metaData(ev.Message)
+		if !bytes.HasPrefix(data, seqDataID) {
+			continue
+		}
+		var meta projectMeta
+		if err := json.Unmarshal(data[len(seqDataID):], &meta); err != nil {
+			s.message = fmt.Sprintf("Ignoring unreadable project settings: %v", err)
+			return false
+		}
+		if meta.Swing != 0 {
+			s.swing = min(max(meta.Swing, minSwing), maxSwing)
+		}
+		for ch, mix := range meta.Mix {
+			if ch < numChannels {
+				s.mix[ch] = channelMix{muted: mix.Muted, soloed: mix.Soloed, volume: min(max(mix.Volume, 0), maxVolume)}
+			}
+		}
+		s.exportAudible = meta.ExportAudible
+		for ch, a := range meta.Arps {
+			if ch < numChannels {
+				s.arps[ch] = a.settings()
+			}
+		}
+		if meta.Seed != 0 {
+			s.seed = meta.Seed
+		}
+		s.exportBars = min(max(meta.ExportBars, 0), maxExportBars)
+		if meta.Scale != nil {
+			s.key = meta.Scale.scaleLock()
+		}
+		if len(meta.Patterns) == 0 {
+			return false
+		}
+
+		for name, p := range meta.Patterns {
+			if slot, ok := parseSlot(name); ok {
+				s.bank[slot] = p.pattern()
+			}
+		}
+		for _, e := range meta.Song {
+			if slot, ok := parseSlot(e.Slot); ok && e.Repeats > 0 {
+				s.song = append(s.song, songEntry{slot: slot, repeats: min(e.Repeats, maxRepeats)})
+			}
+		}
+		s.songMode = meta.SongMode && len(s.song) > 0
+		s.slot, _ = parseSlot(meta.Slot)
+		s.pattern = s.bank[s.slot]
+		return true
+	}
+	return false
+}
+
+// matchesBank reports whether a file's note tracks still play what the
+// pattern bank restored from it saves, rather than edits made in another
+// program.
+func (s *sequencerModel) matchesBank(tracks []smf.Track) bool {
+	if len(tracks) <= numChannels {
+		return false
+	}
+	for ch := range numChannels {
+		if !slices.Equal(trackEvents(tracks[ch+1]), trackEvents(s.noteTrack(ch))) {
+			return false
+		}
+	}
+	return true
+}
+
+// trackEvents lists a track's notes and control changes by tick, sorted so
+// that tracks playing the same compare equal however they were written.
+func trackEvents(track smf.Track) []string {
+	var events []string
+	var tick uint32
+	for _, ev := range track {
+		tick += ev.Delta
+		var channel, key, velocity, controller, value uint8
+		switch {
+		case ev.Message.GetNoteStart(&channel, &key, &velocity):
+			events = append(events, fmt.Sprintf("%d on %d %d %d", tick, channel, key, velocity))
+		case ev.Message.GetNoteEnd(&channel, &key):
+			events = append(events, fmt.Sprintf("%d off %d %d", tick, channel, key))
+		case ev.Message.GetControlChange(&channel, &controller, &value):
+			events = append(events, fmt.Sprintf("%d cc %d %d %d", tick, channel, controller, value))
+		}
+	}
+	slices.Sort(events)
+	return events
+}
+
+// scaleLock restores a scale lock, leaving notes unlocked if the scale is
+// unknown.
+func (m *scaleMeta) scaleLock() scaleLock {
+	var k scaleLock
+	k.root = max(slices.Index(noteNames, m.Root), 0)
+	for i, st := range scaleTypes {
+		if st.name == m.Name {
+			k.scale = i
+		}
+	}
+	for _, d := range m.Custom {
+		if d >= 0 && d < 12 {
+			k.custom[d] = true
+		}
+	}
+	return k
+}
@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"fmt"
+
+	"gitlab.com/gomidi/midi/v2"
+)
+
+const (
+	ccVolume        = 7   // Channel volume controller
+	ccAllNotesOff   = 123 // All notes off controller
+	defaultVolume   = 100 // The usual power-on channel volume
+	maxVolume       = 127
+	volumeIncrement = 8
+)
+
+// channelMix is a channel's mixer settings. They apply to the whole project
+// rather than to one pattern.
+type channelMix struct {
+	muted  bool
+	soloed bool
+	volume int // Sent as CC7
+}
+
+// resetMix unmutes every channel and sets it to the default volume.
+func (s *sequencerModel) resetMix() {
+	for ch := range s.mix {
+		s.mix[ch] = channelMix{volume: defaultVolume}
+	}
+	s.exportAudible = false
+}
+
+// audible reports whether a channel is heard: it isn't muted and, if any
+// channel is soloed, it is one of them.
+func (s *sequencerModel) audible(ch int) bool {
+	if s.mix[ch].muted {
+		return false
+	}
+	for _, mix := range s.mix {
+		if mix.soloed {
+			return s.mix[ch].soloed
+		}
+	}
+	return true
+}
+
+// audibleEvents drops the events of channels that aren't heard.
+func (s *sequencerModel) audibleEvents(events []noteEvent) []noteEvent {
+	var kept []noteEvent
+	for _, ev := range events {
+		if s.audible(int(ev.channel)) {
+			kept = append(kept, ev)
+		}
+	}
+	return kept
+}
+
+// volumeMessage is a channel's CC7 message.
+func (s *sequencerModel) volumeMessage(ch int) midi.Message {
+	return midi.ControlChange(uint8(ch), ccVolume, uint8(min(max(s.mix[ch].volume, 0), maxVolume))) //nolint:gosec // ch is bounded by numChannels, volume is clamped
+}
+
+// sendVolumes sends every channel's volume to the output port.
+func (s *sequencerModel) sendVolumes() {
+	if s.sendFunc == nil {
+		return
+	}
+	for ch := range numChannels {
+		_ = s.sendFunc(s.volumeMessage(ch))
+	}
+}
+
+// toggleMix mutes or solos the cursor's channel. Notes still sounding on
+// channels that fall silent are released.
+func (s *sequencerModel) toggleMix(solo bool) {
+	ch := s.cursorY
+	var was [numChannels]bool
+	for i := range numChannels {
+		was[i] = s.audible(i)
+	}
+
+	if solo {
+		s.mix[ch].soloed = !s.mix[ch].soloed
+		s.message = fmt.Sprintf("Channel %d solo %s", ch+1, onOff(s.mix[ch].soloed))
+	} else {
+		s.mix[ch].muted = !s.mix[ch].muted
+		s.message = fmt.Sprintf("Channel %d mute %s", ch+1, onOff(s.mix[ch].muted))
+	}
+
+	if s.sendFunc == nil {
+		return
+	}
+	for i := range numChannels {
+		if was[i] && !s.audible(i) {
+			_ = s.sendFunc(midi.ControlChange(uint8(i), ccAllNotesOff, 0)) //nolint:gosec // i is bounded by numChannels
+		}
+	}
+}
+
+// changeVolume moves the cursor's channel volume and sends it straight away.
+func (s *sequencerModel) changeVolume(delta int) {
+	ch := s.cursorY
+	s.mix[ch].volume = min(max(s.mix[ch].volume+delta, 0), maxVolume)
+	if s.sendFunc != nil {
+		_ = s.sendFunc(s.volumeMessage(ch))
+	}
+	s.message = fmt.Sprintf("Channel %d volume %d", ch+1, s.mix[ch].volume)
+}
+
+// toggleExportAudible chooses whether saving leaves out the notes of muted
+// and unsoloed channels. The patterns themselves are always kept.
+func (s *sequencerModel) toggleExportAudible() {
+	s.exportAudible = !s.exportAudible
+	if s.exportAudible {
+		s.message = "Saving only the channels being heard; muted channels keep their steps"
+	} else {
+		s.message = "Saving every channel"
+	}
+}
+
+// mixLabel is the mute, solo and volume columns shown next to a channel.
+func (s *sequencerModel) mixLabel(ch int) string {
+	mute, solo := "·", "·"
+	if s.mix[ch].muted {
+		mute = "M"
+	}
+	if s.mix[ch].soloed {
+		solo = "S"
+	}
+	return fmt.Sprintf("%s%s %3d", mute, solo, s.mix[ch].volume)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+func TestMuteAndSolo(t *testing.T) {
+	s := &sequencerModel{}
+	s.resetBank()
+	s.resetMix()
+	for ch := range numChannels {
+		s.steps[ch][0] = true
+	}
+
+	var sent []midi.Message
+	s.sendFunc = func(msg midi.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+
+	heard := func() []bool {
+		var got [numChannels]bool
+		for _, ev := range s.playbackState().patterns[s.slot] {
+			got[ev.channel] = true
+		}
+		return got[:]
+	}
+
+	s.cursorY = 1
+	s.toggleMix(false)
+	if got := heard(); got[1] || !got[0] {
+		t.Errorf("Expected channel 2 to be muted, heard %v", got)
+	}
+	if len(sent) != 1 || !sent[0].Is(midi.ControlChangeMsg) {
+		t.Errorf("Expected muting to release channel 2's notes, sent %v", sent)
+	}
+
+	// Soloing channel 3 leaves only it playing; a muted soloed channel stays quiet
+	s.cursorY = 2
+	s.toggleMix(true)
+	if got := heard(); got[0] || got[1] || !got[2] || got[3] {
+		t.Errorf("Expected only channel 3, heard %v", got)
+	}
+	s.cursorY = 1
+	s.toggleMix(true)
+	if got := heard(); got[1] {
+		t.Error("Expected mute to win over solo")
+	}
+}
+
+func TestMixIsSaved(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "mix.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.steps[0][0] = true
+	s.steps[1][0] = true
+	s.cursorY = 1
+	s.toggleMix(false)
+	s.changeVolume(-2 * volumeIncrement)
+	s.toggleExportAudible()
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	rd, err := smf.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Error reading MIDI: %v", err)
+	}
+	var ch, controller, value uint8
+	if !rd.Tracks[2][0].Message.GetControlChange(&ch, &controller, &value) || controller != ccVolume || value != defaultVolume-2*volumeIncrement {
+		t.Errorf("Expected channel 2's track to start with its volume, got %v", rd.Tracks[2][0].Message)
+	}
+	if len(noteOnTicks(rd.Tracks[1])) != 1 || len(noteOnTicks(rd.Tracks[2])) != 0 {
+		t.Error("Expected the muted channel's notes to be left out of the file")
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if !s2.steps[1][0] || !s2.mix[1].muted || s2.mix[1].volume != defaultVolume-2*volumeIncrement || !s2.exportAudible {
+		t.Errorf("Expected the muted steps and the mix to be restored, got %+v", s2.mix[1])
+	}
+}
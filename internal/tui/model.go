@@ -2,10 +2,10 @@ package tui
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -27,9 +27,6 @@ const (
 	keyRight = "right"
 )
 
-// tickMsg is used for playback animation timing
-type tickMsg time.Time
-
 // Model represents the application state
 type model struct {
 	mode        viewMode
@@ -45,7 +42,8 @@ type fileBrowserModel struct {
 	files       []fileInfo
 	cursor      int
 	message     string
-	viewportTop int // First visible file index
+	viewportTop int            // First visible file index
+	newFile     *newFilePrompt // Open while naming a new file
 }
 
 type fileInfo struct {
@@ -125,7 +123,7 @@ func (fb *fileBrowserModel) loadFiles() {
 		}
 
 		// Include directories and MIDI files
-		if entry.IsDir() || strings.HasSuffix(strings.ToLower(entry.Name()), ".mid") {
+		if entry.IsDir() || isMIDIFile(entry.Name()) {
 			fb.files = append(fb.files, fileInfo{
 				name:  entry.Name(),
 				path:  filepath.Join(fb.currentDir, entry.Name()),
@@ -163,52 +161,87 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		return m, nil
 
-	case tickMsg:
-		// Handle playback tick - only process when playing
-		if m.sequencer.isPlaying && m.mode == sequencerMode {
-			// Save previous step and advance immediately (so visual updates as early as possible)
-			prevStep := m.sequencer.currentStep
-			m.sequencer.currentStep = (m.sequencer.currentStep + 1) % numSteps
-			currentStep := m.sequencer.currentStep
-
-			// Send note offs for previous step's notes (they've been playing since last tick)
-			for ch := 0; ch < numChannels; ch++ {
-				if m.sequencer.steps[ch][prevStep] {
-					// Safe cast: ch is bounded by numChannels (4), notes[ch][prevStep] is bounded by MIDI note range (0-127)
-					m.sequencer.sendNoteOff(uint8(ch), uint8(m.sequencer.notes[ch][prevStep])) //nolint:gosec
-				}
+	case playheadMsg:
+		// Ignore playhead moves from a scheduler that has since been stopped
+		if msg.player != m.sequencer.player {
+			return m, nil
+		}
+		passed := m.sequencer.currentStep
+		m.sequencer.currentStep = msg.step
+		m.sequencer.songPos = msg.songPos
+		if msg.slot != m.sequencer.playingSlot {
+			// A new bar started on another pattern; follow it in the editor
+			m.sequencer.playingSlot = msg.slot
+			m.sequencer.cued = false
+			m.sequencer.selectPattern(msg.slot)
+			m.sequencer.syncPlayer()
+		}
+		// Recording in replace mode erases the steps the playhead passes
+		m.sequencer.erasePassed(passed, msg.step)
+		if m.sequencer.follower != nil {
+			// Track the master's tempo so it is saved with the pattern
+			if bpm := int(math.Round(m.sequencer.follower.tempo())); bpm >= minBPM && bpm <= maxBPM {
+				m.sequencer.bpm = bpm
 			}
+		}
+		return m, waitForPlayhead(msg.player)
 
-			// Send note ons for current step's active notes
-			for ch := 0; ch < numChannels; ch++ {
-				if m.sequencer.steps[ch][currentStep] {
-					// Safe cast: ch is bounded by numChannels (4), notes[ch][currentStep] is bounded by MIDI note range (0-127)
-					m.sequencer.sendNoteOn(uint8(ch), uint8(m.sequencer.notes[ch][currentStep]), 100) //nolint:gosec
-				}
-			}
+	case transportMsg:
+		// Ignore transport from a clock source that has since been closed
+		if msg.follower != m.sequencer.follower {
+			return m, nil
+		}
+		// Only the open sequencer follows the master's transport
+		if m.mode != sequencerMode {
+			return m, waitForTransport(msg.follower)
+		}
+		return m, m.sequencer.handleTransport(msg)
 
-			// Schedule next tick
-			return m, tickWithBPM(m.sequencer.bpm)
+	case noteInputMsg:
+		// Ignore notes from an input that has since been closed
+		if msg.input != m.sequencer.noteIn {
+			return m, nil
 		}
-		return m, nil
+		return m.updateNoteInput(msg)
+
+	case pianoReleaseMsg:
+		return m.updatePianoRelease(msg)
+
+	case autosaveMsg:
+		return m, m.sequencer.handleAutosave(msg)
 
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c":
-			// Close MIDI port before quitting
+			// Keep unsaved changes in the recovery file; they load with the file next time
+			m.sequencer.writeRecovery()
+			// Stop playback and close MIDI ports before quitting
+			m.sequencer.stopPlayback()
 			m.sequencer.closePort()
+			m.sequencer.closeSync()
+			m.sequencer.closeInput()
+			m.sequencer.closeSynth()
 			return m, tea.Quit
 		case "q":
 			if m.mode == fileBrowserMode {
-				// Close MIDI port before quitting
+				if m.fileBrowser.newFile != nil {
+					// Part of the name being typed
+					return m.updateFileBrowser(msg)
+				}
+				// Stop playback and close MIDI ports before quitting
+				m.sequencer.stopPlayback()
 				m.sequencer.closePort()
+				m.sequencer.closeSync()
+				m.sequencer.closeInput()
+				m.sequencer.closeSynth()
 				return m, tea.Quit
-			} else if !m.sequencer.selectingPort {
-				// Return to file browser from sequencer
-				m.mode = fileBrowserMode
-				m.sequencer.isPlaying = false
-				m.sequencer.sendAllNotesOff()
-				return m, nil
+			} else if !m.sequencer.overlayOpen() && !m.sequencer.playingPiano {
+				// Ask before dropping unsaved changes
+				if m.sequencer.dirty {
+					m.sequencer.confirmingLeave = true
+					return m, nil
+				}
+				return m.leaveSequencer(), nil
 			}
 		}
 
@@ -217,6 +250,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case fileBrowserMode:
 			return m.updateFileBrowser(msg)
 		case sequencerMode:
+			if m.sequencer.playingPiano {
+				return m.updatePiano(msg)
+			}
 			return m.updateSequencer(msg)
 		}
 	}
@@ -224,8 +260,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// leaveSequencer stops playback and returns to the file browser, listing any
+// files saved in the meantime.
+func (m model) leaveSequencer() model {
+	m.mode = fileBrowserMode
+	m.sequencer.isPlaying = false
+	m.sequencer.stopPlayback()
+	m.fileBrowser.loadFiles()
+	return m
+}
+
 func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	fb := &m.fileBrowser
+	if fb.newFile != nil {
+		return m.updateNewFile(msg)
+	}
 
 	switch msg.String() {
 	case keyUp, "k":
@@ -270,14 +319,8 @@ func (m model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 		}
 	case "n":
-		// Create new MIDI file
-		newPath := filepath.Join(fb.currentDir, "new_sequence.mid")
-		err := m.sequencer.createNewMIDI(newPath)
-		if err != nil {
-			fb.message = fmt.Sprintf("Error creating MIDI: %v", err)
-		} else {
-			m.mode = sequencerMode
-		}
+		// Name the new MIDI file and pick a template
+		fb.openNewFile()
 	}
 
 	return m, nil
@@ -296,6 +339,9 @@ func (m model) View() string {
 
 func (m model) viewFileBrowser() string {
 	fb := m.fileBrowser
+	if fb.newFile != nil {
+		return m.viewNewFile()
+	}
 
 	s := titleStyle.Render("GENIDI - MIDI Generator") + "\n\n"
 	s += fmt.Sprintf("Current Directory: %s\n\n", fb.currentDir)
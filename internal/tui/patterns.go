@@ -0,0 +1,395 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	numPatterns     = 64 // Four banks, A to D, of 16 slots
+	patternsPerBank = 16
+	maxRepeats      = 16 // Most times a song entry can repeat its pattern
+)
+
+// songEntry plays a bank slot a number of times in a row.
+type songEntry struct {
+	slot    int
+	repeats int
+}
+
+// defaultNotes are the notes each channel starts on (C4, D4, E4, F4).
+var defaultNotes = [numChannels]int{60, 62, 64, 65}
+
+// newPattern returns a pattern with no active steps and every step at its
+// default note, velocity and gate.
+func newPattern() pattern {
+	var p pattern
+	for ch := 0; ch < numChannels; ch++ {
+		for step := 0; step < numSteps; step++ {
+			p.notes[ch][step] = defaultNotes[ch]
+			p.velocities[ch][step] = defaultVelocity
+			p.gates[ch][step] = maxGate
+			p.chances[ch][step] = maxChance
+			p.ratchets[ch][step] = 1
+		}
+	}
+	return p
+}
+
+// resetChannel clears a channel's steps back to how a new pattern starts.
+// Its CC lanes are kept.
+func (p *pattern) resetChannel(ch int) {
+	fresh := newPattern()
+	p.steps[ch] = fresh.steps[ch]
+	p.notes[ch] = fresh.notes[ch]
+	p.velocities[ch] = fresh.velocities[ch]
+	p.gates[ch] = fresh.gates[ch]
+	p.ties[ch] = fresh.ties[ch]
+	p.chords[ch] = fresh.chords[ch]
+	p.nudges[ch] = fresh.nudges[ch]
+	p.chances[ch] = fresh.chances[ch]
+	p.conditions[ch] = fresh.conditions[ch]
+	p.ratchets[ch] = fresh.ratchets[ch]
+}
+
+// isEmpty reports whether no step of the pattern plays and no CC lane is set
+// up.
+func (p *pattern) isEmpty() bool {
+	for ch := range p.steps {
+		if slices.Contains(p.steps[ch][:], true) {
+			return false
+		}
+	}
+	return p.lanes == [numChannels][numLanes]ccLane{}
+}
+
+// slotName names a bank slot, A1 to D16.
+func slotName(slot int) string {
+	return fmt.Sprintf("%c%d", 'A'+slot/patternsPerBank, slot%patternsPerBank+1)
+}
+
+// parseSlot turns a name from slotName back into a slot.
+func parseSlot(name string) (int, bool) {
+	var bank rune
+	var n int
+	if _, err := fmt.Sscanf(name, "%c%d", &bank, &n); err != nil {
+		return 0, false
+	}
+	if bank < 'A' || bank >= 'A'+numPatterns/patternsPerBank || n < 1 || n > patternsPerBank {
+		return 0, false
+	}
+	return int(bank-'A')*patternsPerBank + n - 1, true
+}
+
+// resetBank fills the bank with empty patterns, edits slot A1 and clears
+// the song.
+func (s *sequencerModel) resetBank() {
+	s.bank = make([]pattern, numPatterns)
+	for i := range s.bank {
+		s.bank[i] = newPattern()
+	}
+	s.pattern = s.bank[0]
+	s.slot = 0
+	s.playingSlot = 0
+	s.cued = false
+	s.song = nil
+	s.songMode = false
+	s.songPos = 0
+	s.selectingBank = false
+	s.editingSong = false
+}
+
+// patternAt returns the pattern in a slot, including unstored edits to the
+// one being edited.
+func (s *sequencerModel) patternAt(slot int) pattern {
+	if slot == s.slot {
+		return s.pattern
+	}
+	return s.bank[slot]
+}
+
+// withPattern returns a copy of the sequencer editing the given slot, so
+// patterns other than the one on screen can be rendered.
+func (s *sequencerModel) withPattern(slot int) *sequencerModel {
+	if slot == s.slot {
+		return s
+	}
+	c := *s
+	c.pattern = s.bank[slot]
+	return &c
+}
+
+// selectPattern stores the edited pattern in its slot and starts editing
+// another.
+func (s *sequencerModel) selectPattern(slot int) {
+	if slot == s.slot {
+		return
+	}
+	s.bank[s.slot] = s.pattern
+	s.slot = slot
+	s.pattern = s.bank[slot]
+}
+
+// cuePattern switches to another slot. While a pattern plays the switch
+// waits for the bar to end, so the groove never breaks.
+func (s *sequencerModel) cuePattern(slot int) {
+	if s.isPlaying && !s.songMode && slot != s.slot {
+		s.queued, s.cued = slot, true
+		s.message = fmt.Sprintf("Pattern %s queued for the next bar", slotName(slot))
+		return
+	}
+	s.cued = false
+	s.selectPattern(slot)
+	s.message = fmt.Sprintf("Editing pattern %s", slotName(slot))
+}
+
+// cueNeighbour cues the slot delta away from the one already cued, or from
+// the pattern being edited.
+func (s *sequencerModel) cueNeighbour(delta int) {
+	from := s.slot
+	if s.cued {
+		from = s.queued
+	}
+	if to := from + delta; to >= 0 && to < numPatterns {
+		s.cuePattern(to)
+	}
+}
+
+// timeline lists the slot played in each bar: the song with its repeats in
+// song mode, otherwise just the pattern being edited.
+func (s *sequencerModel) timeline() []int {
+	if !s.songMode || len(s.song) == 0 {
+		return []int{s.slot}
+	}
+	var order []int
+	for _, e := range s.song {
+		for range e.repeats {
+			order = append(order, e.slot)
+		}
+	}
+	return order
+}
+
+// timelineEvents renders one channel bar by bar along a timeline.
+func (s *sequencerModel) timelineEvents(order []int, ch int) []noteEvent {
+	rendered := make(map[int][]noteEvent)
+	var events []noteEvent
+	for bar, slot := range order {
+		bars, ok := rendered[slot]
+		if !ok {
+			bars = s.withPattern(slot).channelEvents(ch)
+			rendered[slot] = bars
+		}
+		for _, ev := range bars {
+			ev.tick += uint32(bar) * patternTicks //nolint:gosec // bar is bounded by the song length
+			events = append(events, ev)
+		}
+	}
+	sortEvents(events)
+	return events
+}
+
+// songStart is the first bar of a song entry on the timeline.
+func (s *sequencerModel) songStart(entry int) int {
+	bar := 0
+	for _, e := range s.song[:entry] {
+		bar += e.repeats
+	}
+	return bar
+}
+
+// songEntryAt finds the song entry playing at a bar of the timeline.
+func (s *sequencerModel) songEntryAt(bar int) int {
+	for i, e := range s.song {
+		if bar < e.repeats {
+			return i
+		}
+		bar -= e.repeats
+	}
+	return -1
+}
+
+// updateBank handles keys in the pattern bank overlay.
+func (s *sequencerModel) updateBank(msg tea.KeyMsg) {
+	switch msg.String() {
+	case keyLeft, "h":
+		if s.bankCursor%patternsPerBank > 0 {
+			s.bankCursor--
+		}
+	case keyRight, "l":
+		if s.bankCursor%patternsPerBank < patternsPerBank-1 {
+			s.bankCursor++
+		}
+	case keyUp, "k":
+		if s.bankCursor >= patternsPerBank {
+			s.bankCursor -= patternsPerBank
+		}
+	case keyDown, "j":
+		if s.bankCursor < numPatterns-patternsPerBank {
+			s.bankCursor += patternsPerBank
+		}
+	case "enter":
+		s.cuePattern(s.bankCursor)
+		s.selectingBank = false
+	case "esc", "q", "b":
+		s.selectingBank = false
+	}
+}
+
+// updateSong handles keys in the song overlay.
+func (s *sequencerModel) updateSong(msg tea.KeyMsg) {
+	switch msg.String() {
+	case keyUp, "k":
+		if s.songCursor > 0 {
+			s.songCursor--
+		}
+	case keyDown, "j":
+		if s.songCursor < len(s.song)-1 {
+			s.songCursor++
+		}
+	case "a":
+		// Add the pattern being edited after the highlighted entry
+		at := 0
+		if len(s.song) > 0 {
+			at = s.songCursor + 1
+		}
+		s.song = slices.Insert(s.song, at, songEntry{slot: s.slot, repeats: 1})
+		s.songCursor = at
+	case "x":
+		if len(s.song) > 0 {
+			s.song = slices.Delete(s.song, s.songCursor, s.songCursor+1)
+			s.songCursor = max(min(s.songCursor, len(s.song)-1), 0)
+			if len(s.song) == 0 {
+				s.songMode = false
+			}
+		}
+	case "+", "=":
+		if len(s.song) > 0 && s.song[s.songCursor].repeats < maxRepeats {
+			s.song[s.songCursor].repeats++
+		}
+	case "-", "_":
+		if len(s.song) > 0 && s.song[s.songCursor].repeats > 1 {
+			s.song[s.songCursor].repeats--
+		}
+	case "m":
+		if len(s.song) == 0 {
+			s.message = "Add patterns to the song first"
+			break
+		}
+		s.songMode = !s.songMode
+		s.cued = false
+		if s.songMode {
+			s.message = "Song mode: playback and export follow the song"
+		} else {
+			s.message = "Pattern mode: playback loops the pattern being edited"
+		}
+	case "enter":
+		// Edit the highlighted entry's pattern
+		if len(s.song) > 0 {
+			s.cuePattern(s.song[s.songCursor].slot)
+			if !s.isPlaying {
+				// Playing from the cursor starts the song here
+				s.songPos = s.songStart(s.songCursor)
+			}
+			s.editingSong = false
+		}
+	case "esc", "q", "g":
+		s.editingSong = false
+	}
+}
+
+func (m model) viewBank() string {
+	s := m.sequencer
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Pattern Bank") + "\n\n")
+
+	b.WriteString("   ")
+	for i := 1; i <= patternsPerBank; i++ {
+		b.WriteString(lipgloss.NewStyle().Width(3).Align(lipgloss.Center).Foreground(lipgloss.Color("#888888")).Render(fmt.Sprint(i)))
+	}
+	b.WriteString("\n")
+
+	for bank := 0; bank < numPatterns/patternsPerBank; bank++ {
+		fmt.Fprintf(&b, "%c  ", 'A'+bank)
+		for i := 0; i < patternsPerBank; i++ {
+			slot := bank*patternsPerBank + i
+			p := s.patternAt(slot)
+
+			cell := "·"
+			cellStyle := lipgloss.NewStyle().Width(3).Align(lipgloss.Center).Foreground(lipgloss.Color("#666666"))
+			if !p.isEmpty() {
+				cell = "■"
+				cellStyle = cellStyle.Foreground(lipgloss.Color("#FFD700"))
+			}
+			if s.cued && slot == s.queued {
+				cell = "→"
+			}
+			if s.isPlaying && slot == s.playingSlot {
+				cellStyle = cellStyle.Background(lipgloss.Color("#7D56F4"))
+			}
+			if slot == s.bankCursor {
+				cellStyle = cellStyle.Background(lipgloss.Color("#5A3DBF"))
+			}
+			b.WriteString(cellStyle.Render(cell))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "\nEditing %s • highlighted %s\n", slotName(s.slot), slotName(s.bankCursor))
+
+	b.WriteString("\n" + helpStyle.Render("↑↓←→ or hjkl: move • enter: switch (queued for the next bar while playing) • q/esc: close"))
+
+	return b.String()
+}
+
+func (m model) viewSong() string {
+	s := m.sequencer
+
+	var b strings.Builder
+
+	mode := "off"
+	if s.songMode {
+		mode = "on"
+	}
+	b.WriteString(titleStyle.Render("Song") + fmt.Sprintf(" (song mode %s)\n\n", mode))
+
+	if len(s.song) == 0 {
+		b.WriteString("No patterns in the song yet.\n")
+	}
+	playing := -1
+	if s.songMode && (s.isPlaying || s.isPaused) {
+		playing = s.songEntryAt(s.songPos)
+	}
+	for i, e := range s.song {
+		cursor := "  "
+		if i == s.songCursor {
+			cursor = "> "
+		}
+		marker := ""
+		if i == playing {
+			marker = " ▶"
+		}
+		line := fmt.Sprintf("%s%2d. %-3s ×%d%s\n", cursor, i+1, slotName(e.slot), e.repeats, marker)
+		if i == s.songCursor {
+			b.WriteString(selectedStyle.Render(line))
+		} else {
+			b.WriteString(line)
+		}
+	}
+
+	b.WriteString("\n")
+	if s.message != "" {
+		b.WriteString(errorStyle.Render(s.message) + "\n")
+	}
+
+	b.WriteString("\n" + helpStyle.Render(fmt.Sprintf("a: add %s after highlighted • x: remove • +/-: repeats • m: song mode on/off", slotName(s.slot))))
+	b.WriteString("\n" + helpStyle.Render("↑/k: up • ↓/j: down • enter: edit pattern • q/esc: close"))
+
+	return b.String()
+}
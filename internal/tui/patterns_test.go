@@ -0,0 +1,244 @@
+package tui
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+func TestSlotNames(t *testing.T) {
+	for _, tt := range []struct {
+		slot int
+		name string
+	}{{0, "A1"}, {15, "A16"}, {16, "B1"}, {63, "D16"}} {
+		if got := slotName(tt.slot); got != tt.name {
+			t.Errorf("Expected slot %d to be %s, got %s", tt.slot, tt.name, got)
+		}
+		if got, ok := parseSlot(tt.name); !ok || got != tt.slot {
+			t.Errorf("Expected %s to parse as %d, got %d", tt.name, tt.slot, got)
+		}
+	}
+	for _, name := range []string{"E1", "A0", "A17", ""} {
+		if _, ok := parseSlot(name); ok {
+			t.Errorf("Expected %q not to parse", name)
+		}
+	}
+}
+
+// noteOnTicks reads the absolute ticks of a track's note ons.
+func noteOnTicks(track smf.Track) []uint32 {
+	var ticks []uint32
+	var tick uint32
+	for _, ev := range track {
+		tick += ev.Delta
+		var ch, key, vel uint8
+		if ev.Message.GetNoteOn(&ch, &key, &vel) && vel > 0 {
+			ticks = append(ticks, tick)
+		}
+	}
+	return ticks
+}
+
+func TestSongExportsLinearTimeline(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "song.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.steps[0][0] = true
+	s.cuePattern(1)
+	s.steps[0][4] = true
+	s.song = []songEntry{{slot: 0, repeats: 2}, {slot: 1, repeats: 1}}
+	s.songMode = true
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	rd, err := smf.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Error reading MIDI: %v", err)
+	}
+	want := []uint32{0, patternTicks, 2*patternTicks + 4*ticksPerStep}
+	if got := noteOnTicks(rd.Tracks[1]); !slices.Equal(got, want) {
+		t.Errorf("Expected note ons at %v, got %v", want, got)
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if s2.slot != 1 || !s2.steps[0][4] || s2.steps[0][0] {
+		t.Errorf("Expected to be editing A2 with step 4, got slot %s", slotName(s2.slot))
+	}
+	if !s2.songMode || len(s2.song) != 2 || s2.song[0].repeats != 2 || s2.song[1].slot != 1 {
+		t.Errorf("Expected the song to be restored, got %v (song mode %v)", s2.song, s2.songMode)
+	}
+	if a1 := s2.patternAt(0); !a1.steps[0][0] {
+		t.Error("Expected A1 to be restored from the bank")
+	}
+}
+
+func TestMultiBarImportFillsSlots(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "bars.mid")
+
+	// A plain two-bar file with no genidi settings
+	sm := smf.New()
+	sm.TimeFormat = smf.MetricTicks(ticksPerQuarterNote)
+	var track0, track1 smf.Track
+	track0.Add(0, smf.MetaTempo(120))
+	track0.Close(0)
+	track1.Add(0, midi.NoteOn(0, 60, 100))
+	track1.Add(ticksPerStep-1, midi.NoteOff(0, 60))
+	track1.Add(patternTicks+2*ticksPerStep-(ticksPerStep-1), midi.NoteOn(0, 67, 100))
+	track1.Add(ticksPerStep-1, midi.NoteOff(0, 67))
+	track1.Close(0)
+	if err := sm.Add(track0); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Add(track1); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.WriteFile(testPath); err != nil {
+		t.Fatalf("Error writing MIDI: %v", err)
+	}
+
+	s := &sequencerModel{}
+	if err := s.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if s.slot != 0 || !s.steps[0][0] || s.notes[0][0] != 60 {
+		t.Error("Expected the first bar in A1")
+	}
+	if a2 := s.patternAt(1); !a2.steps[0][2] || a2.notes[0][2] != 67 {
+		t.Error("Expected the second bar in A2")
+	}
+	if !s.songMode || len(s.timeline()) != 2 {
+		t.Errorf("Expected a two-bar song, got %v", s.song)
+	}
+}
+
+func TestMetaClampsNotes(t *testing.T) {
+	// A hand-edited bank with notes and chord notes outside the MIDI range
+	var p patternMeta
+	p.Steps[0][0], p.Notes[0][0] = true, -1
+	p.Steps[0][1], p.Notes[0][1], p.Chords[0][1] = true, 120, []int{4, 7, 12}
+	p.Steps[0][2], p.Notes[0][2], p.Chords[0][2] = true, 300, []int{-3, 0}
+	data, err := json.Marshal(projectMeta{Patterns: map[string]patternMeta{"A1": p}, Slot: "A1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var track smf.Track
+	track.Add(0, smf.MetaSequencerData(append(slices.Clone(seqDataID), data...)))
+	track.Close(0)
+
+	s := &sequencerModel{}
+	s.resetBank()
+	if !s.readMeta(track) {
+		t.Fatal("Expected the bank to be restored")
+	}
+	if got := s.chordName(0, 0); got != "C-1" {
+		t.Errorf("Expected a negative note raised to C-1, got %s", got)
+	}
+	if !slices.Equal(s.chords[0][1], []int{4, 7}) {
+		t.Errorf("Expected the chord note past G9 dropped, got %v", s.chords[0][1])
+	}
+	if s.notes[0][2] != maxMIDINote || s.chords[0][2] != nil {
+		t.Errorf("Expected a lone G9, got %s", s.chordName(0, 2))
+	}
+}
+
+func TestNotesEditedElsewhereWin(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "daw.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.steps[1][0] = true
+	s.notes[1][0] = 60
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	// Loaded untouched, the bank comes back as saved
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if !s2.steps[1][0] || strings.Contains(s2.message, "outside genidi") {
+		t.Fatalf("Expected the saved bank, got %q", s2.message)
+	}
+
+	// Another program adds a note on step 4 of the second channel
+	rd, err := smf.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Error reading MIDI: %v", err)
+	}
+	var track smf.Track
+	var tick uint32
+	for _, ev := range rd.Tracks[2] {
+		if ev.Message.Is(smf.MetaEndOfTrackMsg) {
+			break
+		}
+		tick += ev.Delta
+		track = append(track, ev)
+	}
+	track.Add(4*ticksPerStep-tick, midi.NoteOn(1, 67, 90))
+	track.Add(ticksPerStep/2, midi.NoteOff(1, 67))
+	track.Close(0)
+	rd.Tracks[2] = track
+	if err := rd.WriteFile(testPath); err != nil {
+		t.Fatalf("Error writing MIDI: %v", err)
+	}
+
+	s3 := &sequencerModel{}
+	if err := s3.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if !s3.steps[1][0] || !s3.steps[1][4] || s3.notes[1][4] != 67 {
+		t.Error("Expected the edited notes to be loaded")
+	}
+	if !strings.Contains(s3.message, "edited outside genidi") {
+		t.Errorf("Expected a message saying why, got %q", s3.message)
+	}
+}
+
+func TestSchedulerSwitchesPatternAtBarEnd(t *testing.T) {
+	s := &sequencerModel{bpm: 120}
+	s.resetBank()
+	s.isPlaying = true
+	s.cuePattern(5)
+	if s.slot != 0 || !s.cued {
+		t.Fatalf("Expected A6 to wait for the bar end, editing %s", slotName(s.slot))
+	}
+
+	state := s.playbackState()
+	p := newScheduler(state, 0, 0)
+	if got := p.advance(state, patternTicks-ticksPerStep); got != patternTicks-ticksPerStep || p.slot != 0 {
+		t.Errorf("Expected no switch mid-bar, got tick %d on %s", got, slotName(p.slot))
+	}
+	if got := p.advance(state, patternTicks); got != 0 || p.slot != 5 {
+		t.Errorf("Expected A6 from the next bar, got tick %d on %s", got, slotName(p.slot))
+	}
+
+	// Song mode walks the timeline, repeats included, and loops back
+	s.cued = false
+	s.song = []songEntry{{slot: 2, repeats: 2}, {slot: 3, repeats: 1}}
+	s.songMode = true
+	state = s.playbackState()
+	p = newScheduler(state, 2, 0)
+	var slots []int
+	for range 4 {
+		p.advance(state, patternTicks)
+		slots = append(slots, p.slot)
+	}
+	if want := []int{2, 3, 2, 2}; !slices.Equal(slots, want) {
+		t.Errorf("Expected song slots %v, got %v", want, slots)
+	}
+}
@@ -0,0 +1,156 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/icco/genidi/internal/audio"
+	"github.com/icco/genidi/internal/piano"
+)
+
+// pianoReleaseMsg ends a note played on the computer keyboard.
+type pianoReleaseMsg struct {
+	press piano.Press
+}
+
+// releasePiano ends a press once it has sounded for piano.Hold.
+func releasePiano(p piano.Press) tea.Cmd {
+	return tea.Tick(piano.Hold, func(time.Time) tea.Msg {
+		return pianoReleaseMsg{press: p}
+	})
+}
+
+// openPiano starts playing the computer keyboard as a piano. Without a MIDI
+// output the notes, and the pattern playing, sound on the built-in synth,
+// which stays open for the session once started.
+func (s *sequencerModel) openPiano() {
+	if s.piano == nil {
+		s.piano = piano.New()
+	}
+	s.playingPiano = true
+	s.message = "Piano: " + s.piano.String()
+	if s.sendFunc == nil && s.synth == nil {
+		synth, err := audio.NewSynth()
+		if err != nil {
+			s.message = fmt.Sprintf("No MIDI output, and the built-in synth failed: %v", err)
+			return
+		}
+		s.synth = synth
+		s.syncPlayer()
+	}
+}
+
+// closePiano goes back to editing, stopping any notes still sounding.
+func (s *sequencerModel) closePiano() {
+	s.playingPiano = false
+	for _, note := range s.piano.Reset() {
+		s.playInput(inputNote{key: note, tapped: true})
+	}
+	s.message = ""
+}
+
+// closeSynth stops the built-in synth.
+func (s *sequencerModel) closeSynth() {
+	if s.synth != nil {
+		s.synth.AllNotesOff()
+		if err := s.synth.Close(); err != nil {
+			s.message = fmt.Sprintf("Error closing synth: %v", err)
+		}
+		s.synth = nil
+	}
+}
+
+// updatePiano handles keys while the computer keyboard is played as a piano.
+// Notes go through playInput like notes from a MIDI input: entered at the
+// cursor when stopped, recorded into the armed channel while playing, or only
+// sounded while auditioning. Those edits manage their own undo history, so
+// keys bypass updateSequencer. Each press enters a step of its own: the
+// terminal reports no releases, so notes played together can't be told from
+// notes played quickly.
+func (m model) updatePiano(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	s := &m.sequencer
+	key := msg.String()
+	if p, ok := s.piano.Play(key); ok {
+		note := inputNote{key: p.Note, velocity: p.Velocity, at: time.Now(), tapped: true}
+		switch {
+		case p.Start && s.auditioning:
+			s.soundInput(note)
+		case p.Start:
+			s.playInput(note)
+		}
+		return m, releasePiano(p)
+	}
+	if s.piano.Adjust(key) {
+		s.message = "Piano: " + s.piano.String()
+		return m, nil
+	}
+
+	switch key {
+	case "tab", "esc":
+		s.closePiano()
+	case "enter":
+		s.auditioning = !s.auditioning
+		s.message = ""
+		if s.auditioning {
+			s.message = "Auditioning: piano notes sound without being entered or recorded"
+		}
+	case keyLeft:
+		if s.cursorX > 0 {
+			s.cursorX--
+		}
+	case keyRight:
+		if s.cursorX < numSteps-1 {
+			s.cursorX++
+		}
+	case keyUp:
+		if s.cursorY > 0 {
+			s.cursorY--
+		}
+	case keyDown:
+		if s.cursorY < numChannels-1 {
+			s.cursorY++
+		}
+	case " ":
+		if s.follower != nil {
+			s.message = "Following external clock: start and stop from the master"
+			break
+		}
+		return m, s.transport("p")
+	}
+	return m, nil
+}
+
+// updatePianoRelease stops a note from the computer keyboard unless its key
+// was pressed again since.
+func (m model) updatePianoRelease(msg pianoReleaseMsg) (tea.Model, tea.Cmd) {
+	if m.sequencer.piano != nil && m.sequencer.piano.Release(msg.press) {
+		m.sequencer.playInput(inputNote{key: msg.press.Note, tapped: true})
+	}
+	return m, nil
+}
+
+// pianoLabel describes the computer keyboard piano and the notes sounding.
+func (s *sequencerModel) pianoLabel() string {
+	out := "MIDI out"
+	if s.sendFunc == nil {
+		out = "built-in synth"
+		if s.synth == nil {
+			out = "nothing (no MIDI output or synth)"
+		}
+	}
+	action := "entering notes"
+	if s.auditioning {
+		action = "auditioning"
+	}
+	label := fmt.Sprintf("Piano: %s • %s • playing on %s", s.piano, action, out)
+	if held := s.piano.Held(); len(held) > 0 {
+		names := make([]string, len(held))
+		for i, note := range held {
+			names[i] = midiNoteToName(int(note))
+		}
+		label += " • " + strings.Join(names, " ")
+	}
+	return label
+}
@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gitlab.com/gomidi/midi/v2"
+)
+
+// playPiano presses keys through Update, which hands them to the piano while
+// it is open.
+func playPiano(m model, keys ...string) model {
+	for _, key := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		switch key {
+		case "tab":
+			msg = tea.KeyMsg{Type: tea.KeyTab}
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		}
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+func TestPianoKeys(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "piano.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	var sent []midi.Message
+	m.sequencer.sendFunc = func(msg midi.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+
+	// Keys go through Update, which hands them to the piano while it is open
+	var releases []pianoReleaseMsg
+	press := func(msgs ...tea.KeyMsg) {
+		for _, msg := range msgs {
+			next, cmd := m.Update(msg)
+			m = next.(model)
+			if cmd != nil {
+				if r, ok := cmd().(pianoReleaseMsg); ok {
+					releases = append(releases, r)
+				}
+			}
+		}
+	}
+	key := func(k string) tea.KeyMsg {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+	}
+
+	// Up an octave, then D4 entered on step 0; q plays nothing and stays put
+	press(tea.KeyMsg{Type: tea.KeyTab}, key("x"), key("s"), key("q"))
+	if !m.sequencer.playingPiano || m.mode != sequencerMode {
+		t.Fatal("Expected the piano to stay open")
+	}
+	if s := m.sequencer; !s.steps[0][0] || s.notes[0][0] != 62 || s.cursorX != 1 {
+		t.Errorf("Expected D4 entered on step 0, got %s with the cursor on %d", s.chordName(0, 0), s.cursorX)
+	}
+
+	// Key repeat holds the note rather than playing it again
+	press(key("s"))
+	for _, r := range releases {
+		next, _ := m.Update(r)
+		m = next.(model)
+	}
+	if len(sent) != 2 || !sent[0].Is(midi.NoteOnMsg) || !sent[1].Is(midi.NoteOffMsg) {
+		t.Errorf("Expected one note on and, after the last press, one note off, got %v", sent)
+	}
+	if m.sequencer.cursorX != 1 {
+		t.Errorf("Expected the repeat not to enter another step, got cursor on %d", m.sequencer.cursorX)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEsc}, key("u"))
+	if m.sequencer.playingPiano || m.sequencer.steps[0][0] {
+		t.Error("Expected esc to leave the piano and u to undo the step entered")
+	}
+}
+
+func TestPianoPressesTakeTheirOwnSteps(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "piano.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	m.sequencer.sendFunc = func(midi.Message) error { return nil }
+
+	// C3 and E3 pressed within piano.Hold of each other, before either releases
+	m = playPiano(m, "tab", "a", "d")
+	s := m.sequencer
+	if s.notes[0][0] != 48 || s.notes[0][1] != 52 || len(s.chords[0][0]) > 0 || s.cursorX != 2 {
+		t.Errorf("Expected C3 and E3 on steps 0 and 1, got %s and %s with the cursor on %d", s.chordName(0, 0), s.chordName(0, 1), s.cursorX)
+	}
+
+	// Notes from a MIDI input held together still make a chord
+	m.sequencer.playInput(inputNote{key: 60, velocity: 100})
+	m.sequencer.playInput(inputNote{key: 64, velocity: 100})
+	if s := m.sequencer; len(s.chords[0][2]) != 1 || s.cursorX != 3 {
+		t.Errorf("Expected a chord on step 2, got %s with the cursor on %d", s.chordName(0, 2), s.cursorX)
+	}
+}
+
+func TestPianoAudition(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "piano.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	var sent []midi.Message
+	m.sequencer.sendFunc = func(msg midi.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+
+	m = playPiano(m, "tab", "enter", "a")
+	if s := m.sequencer; s.steps[0][0] || s.cursorX != 0 || s.dirty {
+		t.Errorf("Expected an auditioned note not to be entered, got %s with the cursor on %d", s.chordName(0, 0), s.cursorX)
+	}
+	if len(sent) != 1 || !sent[0].Is(midi.NoteOnMsg) {
+		t.Errorf("Expected the auditioned note to sound, got %v", sent)
+	}
+
+	// Back to entering notes
+	m = playPiano(m, "enter", "d")
+	if s := m.sequencer; !s.steps[0][0] || s.notes[0][0] != 52 {
+		t.Errorf("Expected E3 entered on step 0, got %s", s.chordName(0, 0))
+	}
+}
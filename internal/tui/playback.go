@@ -0,0 +1,518 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/icco/genidi/internal/audio"
+	"gitlab.com/gomidi/midi/v2"
+)
+
+// clockTicks is the spacing of MIDI Timing Clock pulses in SMF ticks. MIDI
+// clock runs at 24 pulses per quarter note, so six pulses make a step.
+const clockTicks = uint32(ticksPerQuarterNote / 24)
+
+// maxSongPosition is the furthest a Song Position Pointer can point, in beats.
+const maxSongPosition = 1<<14 - 1
+
+// loopColor marks the looped steps in the grid header.
+var loopColor = lipgloss.Color("#00AAFF")
+
+// ticksToDuration converts a tick count to wall-clock time at the given tempo.
+// The division happens last so long runs don't accumulate rounding error.
+func ticksToDuration(ticks uint64, bpm int) time.Duration {
+	return time.Duration(ticks * uint64(time.Minute) / uint64(bpm*ticksPerQuarterNote)) //nolint:gosec // bpm is bounded by minBPM/maxBPM
+}
+
+// playbackState is everything the scheduler needs to play the pattern. The
+// TUI renders a fresh one after every edit so the goroutine never reads the
+// model directly.
+type playbackState struct {
+	patterns  map[int][]noteEvent // Events of each slot that may play, sorted by tick
+	positions [numSteps]uint32
+	loopStart uint32 // Playback jumps back here on reaching loopEnd
+	loopEnd   uint32
+	next      int   // Slot to play after this bar in pattern mode
+	song      []int // Slot for each bar in song mode
+	bpm       int
+	seed      uint64 // Seeds the dice of probability steps
+	send      func(msg midi.Message) error
+}
+
+func (s *sequencerModel) playbackState() playbackState {
+	state := playbackState{loopEnd: patternTicks, next: s.slot, bpm: s.bpm, seed: s.seed, send: s.sendFunc}
+	if state.send == nil && s.synth != nil {
+		state.send = synthSend(s.synth)
+	}
+	slots := []int{s.slot, s.playingSlot}
+	if s.songMode && len(s.song) > 0 {
+		state.song = s.timeline()
+		slots = append(slots, state.song...)
+	} else {
+		if s.cued {
+			state.next = s.queued
+			slots = append(slots, s.queued)
+		}
+		if s.looping {
+			state.loopStart = uint32(s.loopStart) * ticksPerStep //nolint:gosec // loopStart is bounded by numSteps
+			state.loopEnd = uint32(s.loopEnd+1) * ticksPerStep   //nolint:gosec // loopEnd is bounded by numSteps
+		}
+	}
+
+	state.patterns = make(map[int][]noteEvent)
+	for _, slot := range slots {
+		if _, ok := state.patterns[slot]; !ok {
+			state.patterns[slot] = state.loopEvents(s.audibleEvents(s.withPattern(slot).patternEvents()))
+		}
+	}
+	for step := 0; step < numSteps; step++ {
+		state.positions[step] = s.stepPosition(step)
+	}
+	return state
+}
+
+// loopEvents keeps the notes that start inside the loop, and the control
+// changes sent inside it. Note offs may fall past the loop end; the scheduler
+// carries those into the next pass.
+func (state playbackState) loopEvents(events []noteEvent) []noteEvent {
+	type key struct{ channel, note uint8 }
+	playing := make(map[key]bool) // Whether each sounding note was kept
+	var kept []noteEvent
+	for _, ev := range events {
+		if ev.control {
+			if ev.tick >= state.loopStart && ev.tick < state.loopEnd {
+				kept = append(kept, ev)
+			}
+			continue
+		}
+		k := key{ev.channel, ev.note}
+		if ev.velocity > 0 {
+			playing[k] = ev.tick >= state.loopStart && ev.tick < state.loopEnd
+		}
+		if playing[k] {
+			kept = append(kept, ev)
+		}
+		if ev.velocity == 0 {
+			delete(playing, k)
+		}
+	}
+	return kept
+}
+
+// scheduler plays the pattern on its own goroutine. It sleeps until absolute
+// deadlines measured from when playback started, so timing neither drifts
+// with rounding nor stalls while the UI is busy, and sends MIDI directly.
+// The TUI only hears about playhead moves.
+type scheduler struct {
+	mu    sync.Mutex
+	state playbackState
+
+	wake     chan struct{} // Signals that state changed
+	stop     chan struct{}
+	done     chan struct{}
+	playhead chan playhead // Latest position only
+
+	// Owned by the goroutine once running
+	slot    int         // Slot playing
+	songPos int         // Bar of the song playing
+	pass    int         // Passes through the bar or loop so far, for conditions and dice
+	carry   []noteEvent // Note offs left over from the previous bar, rebased
+
+	// Last tick played and when, guarded by mu, for placing notes played live
+	lastTick uint32
+	lastTime time.Time
+}
+
+// playhead is where the scheduler has got to.
+type playhead struct {
+	step    int
+	slot    int
+	songPos int
+}
+
+func newScheduler(state playbackState, slot, songPos int) *scheduler {
+	return &scheduler{
+		state:    state,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		playhead: make(chan playhead, 1),
+		slot:     slot,
+		songPos:  songPos,
+		pass:     songPos,
+	}
+}
+
+// synthSend plays note messages on the built-in synth, for playback without a
+// MIDI output. The synth has no use for clock and transport messages.
+func synthSend(synth *audio.Synth) func(msg midi.Message) error {
+	return func(msg midi.Message) error {
+		var channel, key, velocity uint8
+		switch {
+		case msg.GetNoteStart(&channel, &key, &velocity):
+			synth.NoteOn(channel, key, velocity)
+		case msg.GetNoteEnd(&channel, &key):
+			synth.NoteOff(channel, key)
+		case msg.GetControlChange(&channel, &key, &velocity) && key == ccAllNotesOff:
+			synth.AllNotesOff()
+		}
+		return nil
+	}
+}
+
+// sendMsg sends to the output port, if one is connected.
+func (state playbackState) sendMsg(msg midi.Message) {
+	if state.send != nil {
+		_ = state.send(msg)
+	}
+}
+
+// update swaps in a newly rendered pattern, tempo or output port.
+func (p *scheduler) update(state playbackState) {
+	p.mu.Lock()
+	p.state = state
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *scheduler) current() playbackState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// halt stops the goroutine and waits for it to exit, so no more MIDI is sent
+// once it returns.
+func (p *scheduler) halt() {
+	close(p.stop)
+	<-p.done
+}
+
+// run plays from the given step until halted, acting as MIDI clock master.
+// Playing from the top of the song sends Start; anywhere else, or resuming a
+// pause, is announced with a Song Position Pointer and Continue so followers
+// relocate first rather than reset. A loop inside the bar sends a Song
+// Position Pointer each time it jumps back.
+func (p *scheduler) run(fromStep int, resume bool) {
+	defer close(p.done)
+	defer close(p.playhead)
+
+	// pos counts ticks played across loops; start is when tick origin plays
+	state := p.current()
+	loopTick := uint32(fromStep) * ticksPerStep //nolint:gosec // fromStep is bounded by numSteps
+	var pos, origin uint64
+	start := time.Now()
+
+	// Song position counts MIDI beats, which are 16th notes like steps
+	if beat := min(p.songPos*numSteps+fromStep, maxSongPosition); beat == 0 && !resume {
+		state.sendMsg(midi.Start())
+	} else {
+		state.sendMsg(midi.SPP(uint16(beat))) //nolint:gosec // beat is clamped to maxSongPosition
+		state.sendMsg(midi.Continue())
+	}
+
+	for {
+		// The clock pulse goes first so followers line up with the notes
+		if loopTick%clockTicks == 0 {
+			state.sendMsg(midi.TimingClock())
+		}
+		p.play(state, loopTick)
+
+	wait:
+		nextLoopTick := p.nextTick(state, loopTick)
+		next := pos + uint64(nextLoopTick-loopTick)
+		timer := time.NewTimer(time.Until(start.Add(ticksToDuration(next-origin, state.bpm))))
+		select {
+		case <-p.stop:
+			timer.Stop()
+			return
+		case <-p.wake:
+			timer.Stop()
+			newState := p.current()
+			if newState.bpm != state.bpm {
+				// Re-anchor at the last played tick so the new tempo only
+				// affects time from here on
+				start = start.Add(ticksToDuration(pos-origin, state.bpm))
+				origin = pos
+			}
+			state = newState
+			goto wait
+		case <-timer.C:
+			pos = next
+			loopTick = p.advance(state, nextLoopTick)
+			if nextLoopTick >= state.loopEnd && (state.loopStart > 0 || state.loopEnd < patternTicks) {
+				// A loop inside the bar jumps back mid-bar, so tell followers
+				// where before the next clock pulse
+				beat := min(p.songPos*numSteps+int(state.loopStart/ticksPerStep), maxSongPosition)
+				state.sendMsg(midi.SPP(uint16(beat))) //nolint:gosec // beat is clamped to maxSongPosition
+			}
+		}
+	}
+}
+
+// runFollowing plays from the given step in time with an external clock.
+// Every clock position waits for the master's pulse; events in between are
+// placed using the tempo derived from recent pulses. No transport or clock
+// messages are sent, since the master owns them.
+func (p *scheduler) runFollowing(fromStep int, clock *clockFollower) {
+	defer close(p.done)
+	defer close(p.playhead)
+
+	state := p.current()
+	loopTick := uint32(fromStep) * ticksPerStep //nolint:gosec // fromStep is bounded by numSteps
+	var pos, pulseTick uint64
+	lastPulse := time.Now()
+
+	for {
+		if loopTick%clockTicks == 0 {
+			select {
+			case <-p.stop:
+				return
+			case <-p.wake:
+				state = p.current()
+				continue
+			case lastPulse = <-clock.pulses:
+				pulseTick = pos
+			}
+		} else {
+			bpm := clock.tempo()
+			if bpm <= 0 {
+				bpm = float64(state.bpm)
+			}
+			// A pulse arriving early waits in the channel and is picked up at
+			// the next clock position, so we never run ahead of the master
+			offset := time.Duration(float64(pos-pulseTick) * float64(time.Minute) / (bpm * ticksPerQuarterNote))
+			timer := time.NewTimer(time.Until(lastPulse.Add(offset)))
+			select {
+			case <-p.stop:
+				timer.Stop()
+				return
+			case <-p.wake:
+				timer.Stop()
+				state = p.current()
+				continue
+			case <-timer.C:
+			}
+		}
+
+		p.play(state, loopTick)
+		next := p.nextTick(state, loopTick)
+		pos += uint64(next - loopTick)
+		loopTick = p.advance(state, next)
+	}
+}
+
+// play sends the events due at loopTick and moves the playhead. Notes
+// carried over from the previous bar are released first. Steps with a chance
+// or condition are left out on passes they don't play.
+func (p *scheduler) play(state playbackState, loopTick uint32) {
+	p.mu.Lock()
+	p.lastTick, p.lastTime = loopTick, time.Now()
+	p.mu.Unlock()
+
+	for i, events := range [][]noteEvent{p.carry, state.patterns[p.slot]} {
+		for _, ev := range events {
+			if ev.tick != loopTick {
+				continue
+			}
+			if i > 0 && ev.trig != 0 && !ev.rule.fires(state.seed, p.pass, ev.trig) {
+				continue
+			}
+			state.sendMsg(ev.message())
+		}
+	}
+	p.movePlayhead(state, loopTick)
+}
+
+// tickAt estimates the tick of the bar or loop playing at t from the last
+// tick played. A time before the first tick is placed at the top of the bar.
+func (p *scheduler) tickAt(t time.Time) uint32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lastTime.IsZero() {
+		return p.lastTick
+	}
+	ticks := int64(t.Sub(p.lastTime)) * int64(p.state.bpm*ticksPerQuarterNote) / int64(time.Minute)
+	return uint32(max(int64(p.lastTick)+ticks, 0)) //nolint:gosec // clamped to zero, and a bar is far below the uint32 range
+}
+
+// nextTick finds the next clock pulse, event or step boundary after loopTick.
+func (p *scheduler) nextTick(state playbackState, loopTick uint32) uint32 {
+	next := (loopTick/clockTicks + 1) * clockTicks
+	if state.loopEnd > loopTick && state.loopEnd < next {
+		next = state.loopEnd
+	}
+	for _, ev := range slices.Concat(p.carry, state.patterns[p.slot]) {
+		if ev.tick > loopTick && ev.tick < next {
+			next = ev.tick
+		}
+	}
+	for _, pos := range state.positions {
+		if pos > loopTick && pos < next {
+			next = pos
+		}
+	}
+	return next
+}
+
+// advance moves on to the next tick. Reaching the end of the bar or loop, or
+// finding itself past it after the loop was narrowed, it jumps back to the
+// loop start, carries note offs still due into the new pass and picks the
+// next pattern: the next bar of the song, or the cued slot.
+func (p *scheduler) advance(state playbackState, tick uint32) uint32 {
+	if tick < state.loopEnd {
+		return tick
+	}
+
+	var carry []noteEvent
+	for _, ev := range slices.Concat(p.carry, state.patterns[p.slot]) {
+		if ev.isNoteOff() && ev.tick >= tick {
+			ev.tick = ev.tick - tick + state.loopStart
+			carry = append(carry, ev)
+		}
+	}
+	p.carry = carry
+	p.pass++
+
+	if len(state.song) > 0 {
+		p.songPos = (p.songPos + 1) % len(state.song)
+		p.slot = state.song[p.songPos]
+	} else {
+		p.slot = state.next
+	}
+	return state.loopStart
+}
+
+func (p *scheduler) movePlayhead(state playbackState, loopTick uint32) {
+	step := 0
+	for i, pos := range state.positions {
+		if pos <= loopTick {
+			step = i
+		}
+	}
+	// Drop a stale position the TUI hasn't picked up yet
+	select {
+	case <-p.playhead:
+	default:
+	}
+	p.playhead <- playhead{step: step, slot: p.slot, songPos: p.songPos}
+}
+
+// playheadMsg tells the TUI where playback is so it can redraw.
+type playheadMsg struct {
+	player *scheduler
+	playhead
+}
+
+// waitForPlayhead blocks until the scheduler moves the playhead. It returns
+// nil once playback stops, ending the chain of waits.
+func waitForPlayhead(p *scheduler) tea.Cmd {
+	return func() tea.Msg {
+		pos, ok := <-p.playhead
+		if !ok {
+			return nil
+		}
+		return playheadMsg{player: p, playhead: pos}
+	}
+}
+
+// startPlayback starts the scheduler from the top of the pattern, or of the
+// song in song mode.
+func (s *sequencerModel) startPlayback() tea.Cmd {
+	s.songPos = 0
+	return s.startPlaybackAt(0)
+}
+
+// startPlaybackAt starts the scheduler from the given step.
+func (s *sequencerModel) startPlaybackAt(step int) tea.Cmd {
+	resume := s.isPaused
+	s.stopPlayer()
+	s.isPlaying = true
+	s.isPaused = false
+	s.currentStep = step
+	if order := s.timeline(); s.songMode && len(s.song) > 0 {
+		if s.songPos >= len(order) {
+			s.songPos = 0
+		}
+		s.selectPattern(order[s.songPos])
+	}
+	s.playingSlot = s.slot
+	s.take = nil
+	s.player = newScheduler(s.playbackState(), s.slot, s.songPos)
+	if s.follower != nil {
+		go s.player.runFollowing(step, s.follower)
+	} else {
+		go s.player.run(step, resume)
+	}
+	return waitForPlayhead(s.player)
+}
+
+// transport handles the transport keys: p plays, pauses and continues, P
+// plays from the cursor column or jumps there while playing, and S stops and
+// rewinds.
+func (s *sequencerModel) transport(key string) tea.Cmd {
+	switch key {
+	case "p":
+		switch {
+		case s.isPlaying:
+			s.pausePlayback()
+		case s.isPaused:
+			return s.startPlaybackAt(s.currentStep)
+		case s.looping:
+			return s.startPlaybackAt(s.loopStart)
+		default:
+			return s.startPlayback()
+		}
+	case "P":
+		if s.isPlaying {
+			// Tell followers we're relocating before the Song Position Pointer
+			s.haltPlayback()
+		}
+		return s.startPlaybackAt(s.cursorX)
+	case "S":
+		s.isPlaying = false
+		s.stopPlayback()
+	}
+	return nil
+}
+
+// markLoop sets the loop range in two presses, start then end, and a third
+// press turns looping off again.
+func (s *sequencerModel) markLoop() {
+	switch {
+	case s.looping:
+		s.looping = false
+		s.message = "Loop off"
+	case s.markingLoop:
+		s.markingLoop = false
+		s.looping = true
+		s.loopStart, s.loopEnd = min(s.loopStart, s.cursorX), max(s.loopStart, s.cursorX)
+		s.message = fmt.Sprintf("Looping steps %X-%X", s.loopStart, s.loopEnd)
+	default:
+		s.markingLoop = true
+		s.loopStart = s.cursorX
+		s.message = fmt.Sprintf("Loop from step %X: move to the last step and press L", s.loopStart)
+	}
+}
+
+// stopPlayer halts the scheduler, if one is running.
+func (s *sequencerModel) stopPlayer() {
+	if s.player != nil {
+		s.player.halt()
+		s.player = nil
+	}
+}
+
+// syncPlayer hands the scheduler the latest pattern after an edit.
+func (s *sequencerModel) syncPlayer() {
+	if s.player != nil {
+		s.player.update(s.playbackState())
+	}
+}
@@ -0,0 +1,254 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icco/genidi/internal/audio"
+	"gitlab.com/gomidi/midi/v2"
+)
+
+func TestTicksToDurationDoesNotDrift(t *testing.T) {
+	// 100 bars at 133 BPM is exactly 400 beats of 60/133 seconds
+	got := ticksToDuration(uint64(patternTicks)*100, 133)
+	want := 400 * time.Minute / 133
+	if diff := got - want; diff < -time.Microsecond || diff > time.Microsecond {
+		t.Errorf("Expected %v, got %v (off by %v)", want, got, diff)
+	}
+}
+
+func TestSchedulerPlaysPattern(t *testing.T) {
+	s := &sequencerModel{bpm: 300}
+	s.steps[0][0] = true
+	s.notes[0][0] = 60
+	s.steps[1][2] = true
+	s.notes[1][2] = 64
+
+	type sent struct {
+		msg midi.Message
+		at  time.Time
+	}
+	out := make(chan sent, 64)
+	s.sendFunc = func(msg midi.Message) error {
+		out <- sent{msg: msg, at: time.Now()}
+		return nil
+	}
+
+	cmd := s.startPlayback()
+	defer s.stopPlayer()
+	if cmd == nil {
+		t.Fatal("Expected a command waiting on the playhead")
+	}
+
+	var ons []sent
+	timeout := time.After(2 * time.Second)
+	for len(ons) < 2 {
+		select {
+		case m := <-out:
+			var ch, key, vel uint8
+			if m.msg.GetNoteOn(&ch, &key, &vel) && vel > 0 {
+				ons = append(ons, m)
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for note ons, got %d", len(ons))
+		}
+	}
+
+	var key uint8
+	if ons[0].msg.GetNoteOn(nil, &key, nil); key != 60 {
+		t.Errorf("Expected first note 60, got %d", key)
+	}
+	if ons[1].msg.GetNoteOn(nil, &key, nil); key != 64 {
+		t.Errorf("Expected second note 64, got %d", key)
+	}
+
+	// Two steps at 300 BPM is 100ms
+	if gap := ons[1].at.Sub(ons[0].at); gap < 80*time.Millisecond || gap > 150*time.Millisecond {
+		t.Errorf("Expected about 100ms between notes, got %v", gap)
+	}
+}
+
+func TestSchedulerSendsClock(t *testing.T) {
+	tests := []struct {
+		name     string
+		fromStep int
+		paused   bool
+		want     []midi.Message
+	}{
+		{"from the top", 0, false, []midi.Message{midi.Start(), midi.TimingClock()}},
+		{"relocated", 4, false, []midi.Message{midi.SPP(4), midi.Continue(), midi.TimingClock()}},
+		{"resumed at the top", 0, true, []midi.Message{midi.SPP(0), midi.Continue(), midi.TimingClock()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &sequencerModel{bpm: 300, isPaused: tt.paused}
+			out := make(chan midi.Message, 256)
+			s.sendFunc = func(msg midi.Message) error {
+				out <- msg
+				return nil
+			}
+
+			s.startPlaybackAt(tt.fromStep)
+			defer s.stopPlayer()
+
+			var got []midi.Message
+			clocks := 0
+			timeout := time.After(2 * time.Second)
+			// Six pulses make a step, so wait for a little over one step
+			for clocks < 7 {
+				select {
+				case msg := <-out:
+					got = append(got, msg)
+					if msg.Is(midi.TimingClockMsg) {
+						clocks++
+					}
+				case <-timeout:
+					t.Fatalf("Timed out waiting for clock pulses, got %d", clocks)
+				}
+			}
+
+			for i, want := range tt.want {
+				if got[i].String() != want.String() {
+					t.Errorf("Expected message %d to be %v, got %v", i, want, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPauseAndContinue(t *testing.T) {
+	s := &sequencerModel{bpm: 120}
+	var got []midi.Message
+	s.sendFunc = func(msg midi.Message) error {
+		got = append(got, msg)
+		return nil
+	}
+
+	s.startPlaybackAt(5)
+	s.transport("p")
+	if s.isPlaying || !s.isPaused || s.currentStep != 5 {
+		t.Fatalf("Expected pause at step 5, got playing=%v paused=%v step=%d", s.isPlaying, s.isPaused, s.currentStep)
+	}
+	if last := got[len(got)-1]; !last.Is(midi.StopMsg) {
+		t.Errorf("Expected pause to send Stop, got %v", last)
+	}
+
+	got = nil
+	s.transport("p")
+	defer s.stopPlayer()
+	if !s.isPlaying || s.isPaused || s.currentStep != 5 {
+		t.Fatalf("Expected to continue at step 5, got playing=%v paused=%v step=%d", s.isPlaying, s.isPaused, s.currentStep)
+	}
+
+	s.transport("S")
+	if s.isPlaying || s.isPaused || s.currentStep != 0 {
+		t.Errorf("Expected stop to rewind, got playing=%v paused=%v step=%d", s.isPlaying, s.isPaused, s.currentStep)
+	}
+}
+
+func TestLoopWrapSendsSongPosition(t *testing.T) {
+	s := &sequencerModel{bpm: 300, looping: true, loopStart: 4, loopEnd: 5}
+	out := make(chan midi.Message, 256)
+	s.sendFunc = func(msg midi.Message) error {
+		out <- msg
+		return nil
+	}
+
+	s.startPlaybackAt(4)
+	defer s.stopPlayer()
+
+	// Two steps of pulses, then the jump back is announced before the next
+	var got []midi.Message
+	timeout := time.After(2 * time.Second)
+	for len(got) < 2+12+2 {
+		select {
+		case msg := <-out:
+			got = append(got, msg)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for the loop to wrap, got %v", got)
+		}
+	}
+	clocks := 0
+	for _, msg := range got[2:14] {
+		if msg.Is(midi.TimingClockMsg) {
+			clocks++
+		}
+	}
+	if clocks != 12 || got[14].String() != midi.SPP(4).String() || !got[15].Is(midi.TimingClockMsg) {
+		t.Errorf("Expected 12 pulses, then SPP 4 before the next pulse, got %v", got)
+	}
+}
+
+func TestLoopRange(t *testing.T) {
+	s := &sequencerModel{bpm: 120}
+	for step := 0; step < numSteps; step++ {
+		s.steps[0][step] = true
+		s.notes[0][step] = 60
+	}
+	// A note held from the last looped step must not hang past the loop
+	s.ties[0][7] = true
+
+	s.cursorX = 7
+	s.markLoop()
+	s.cursorX = 4
+	s.markLoop()
+	if !s.looping || s.loopStart != 4 || s.loopEnd != 7 {
+		t.Fatalf("Expected loop 4-7, got looping=%v %d-%d", s.looping, s.loopStart, s.loopEnd)
+	}
+
+	state := s.playbackState()
+	start, end := uint32(4*ticksPerStep), uint32(8*ticksPerStep)
+	ons, offs := 0, 0
+	for _, ev := range state.patterns[0] {
+		if ev.velocity > 0 {
+			ons++
+			if ev.tick < start || ev.tick >= end {
+				t.Errorf("Expected notes to start inside the loop, got tick %d", ev.tick)
+			}
+		} else {
+			offs++
+		}
+	}
+	if ons != 4 || offs != 4 {
+		t.Errorf("Expected 4 note ons and offs, got %d and %d", ons, offs)
+	}
+
+	p := newScheduler(state, 0, 0)
+	if got := p.nextTick(state, end-1); got != end {
+		t.Errorf("Expected the loop end to be scheduled, got %d", got)
+	}
+	if got := p.advance(state, end); got != start {
+		t.Errorf("Expected the loop end to wrap to %d, got %d", start, got)
+	}
+	if len(p.carry) != 1 || p.carry[0].tick < start || p.carry[0].tick >= end {
+		t.Errorf("Expected the held note to be released in the next pass, got %v", p.carry)
+	}
+
+	s.markLoop()
+	if s.looping {
+		t.Error("Expected a third press to turn looping off")
+	}
+}
+
+func TestPlaybackFallsBackToSynth(t *testing.T) {
+	s := &sequencerModel{bpm: 120, synth: &audio.Synth{}}
+	state := s.playbackState()
+	if state.send == nil {
+		t.Fatal("Expected playback without a MIDI output to play on the built-in synth")
+	}
+	// Clock and transport have nothing to do on the synth
+	state.sendMsg(midi.TimingClock())
+	state.sendMsg(midi.Start())
+
+	// A MIDI output takes over from the synth
+	var sent []midi.Message
+	s.sendFunc = func(msg midi.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	s.playbackState().sendMsg(midi.TimingClock())
+	if len(sent) != 1 {
+		t.Errorf("Expected playback on the MIDI output, got %v", sent)
+	}
+}
@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// textPrompt is a one-line text input, used to ask for file names.
+type textPrompt struct {
+	title  string
+	value  string
+	err    string // Why the last submitted value was refused
+	warned string // Value the user was already warned about, to confirm on a second enter
+}
+
+// update edits the text and reports whether enter submitted it or esc
+// cancelled the prompt.
+func (p *textPrompt) update(msg tea.KeyMsg) (submitted, cancelled bool) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		return true, false
+	case tea.KeyEsc:
+		return false, true
+	case tea.KeyBackspace:
+		if runes := []rune(p.value); len(runes) > 0 {
+			p.value = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		p.value += string(msg.Runes)
+	default:
+		return false, false
+	}
+	// Any change needs confirming afresh
+	p.err = ""
+	p.warned = ""
+	return false, false
+}
+
+func (p *textPrompt) view() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(p.title) + "\n\n")
+	b.WriteString(selectedStyle.Render("> "+p.value) + "█\n")
+	if p.err != "" {
+		b.WriteString("\n" + errorStyle.Render(p.err) + "\n")
+	}
+	b.WriteString("\n" + helpStyle.Render("enter: confirm • esc: cancel"))
+
+	return b.String()
+}
+
+// midiFilePath validates a file name typed into a prompt and returns its
+// path in dir. The .mid extension is added when missing.
+func midiFilePath(dir, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", fmt.Errorf("enter a file name")
+	case strings.ContainsAny(name, `/\`):
+		return "", fmt.Errorf("the name can't contain a path separator")
+	case strings.HasPrefix(name, "."):
+		return "", fmt.Errorf("the name can't start with a dot, or the file browser would hide it")
+	case strings.ContainsAny(name, "<>:\"|?*") || strings.ContainsFunc(name, func(r rune) bool { return r < ' ' }):
+		return "", fmt.Errorf("the name contains characters not allowed in file names")
+	}
+	if !isMIDIFile(name) {
+		name += ".mid"
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// isMIDIFile reports whether a file name has the extension the file browser
+// lists.
+func isMIDIFile(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".mid")
+}
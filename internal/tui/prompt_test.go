@@ -0,0 +1,32 @@
+package tui
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMIDIFilePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"groove", "groove.mid", false},
+		{"  Groove.MID ", "Groove.MID", false},
+		{"", "", true},
+		{"sub/dir", "", true},
+		{".hidden", "", true},
+		{"what?", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := midiFilePath("songs", tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("midiFilePath(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != filepath.Join("songs", tt.want) {
+			t.Errorf("midiFilePath(%q) = %q, want %q", tt.name, got, filepath.Join("songs", tt.want))
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/drivers"
+)
+
+// recordColor marks the title while a channel is armed.
+var recordColor = lipgloss.Color("#E0245E")
+
+var recordStyle = lipgloss.NewStyle().
+	Bold(true).
+	Foreground(lipgloss.Color("#FFFFFF")).
+	Background(recordColor).
+	Padding(0, 1)
+
+// recordMode is what recording does to the armed channel's existing steps.
+type recordMode int
+
+const (
+	recordOff     recordMode = iota
+	recordOverdub            // Played notes are added to the steps already there
+	recordReplace            // Steps are erased as the playhead passes, keeping only what is played
+	numRecordModes
+)
+
+var recordModeNames = [numRecordModes]string{"off", "overdub", "replace"}
+
+func (r recordMode) String() string {
+	return recordModeNames[r]
+}
+
+// inputNote is a note played on the MIDI input, stamped with when it arrived
+// so it can be placed on the step it was played on.
+type inputNote struct {
+	key      uint8
+	velocity uint8 // zero for note off
+	at       time.Time
+	tapped   bool // Played on the computer keyboard, whose keys are never held for a chord
+}
+
+// noteInput listens to a MIDI input for notes played on a keyboard. Notes go
+// through the TUI, which owns the pattern.
+type noteInput struct {
+	in         drivers.In
+	stopListen func()
+	notes      chan inputNote
+
+	mu     sync.Mutex
+	closed bool // Whether notes is closed, once the input is
+}
+
+func newNoteInput(in drivers.In) (*noteInput, error) {
+	n := &noteInput{in: in, notes: make(chan inputNote, 64)}
+	stop, err := midi.ListenTo(in, n.receive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen to %s: %w", in.String(), err)
+	}
+	n.stopListen = stop
+	return n, nil
+}
+
+func (n *noteInput) receive(msg midi.Message, _ int32) {
+	var channel, key, velocity uint8
+	note := inputNote{at: time.Now()}
+	switch {
+	case msg.GetNoteStart(&channel, &key, &velocity):
+		note.key, note.velocity = key, velocity
+	case msg.GetNoteEnd(&channel, &key):
+		note.key = key
+	default:
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.notes <- note:
+	default:
+		// The TUI is too busy to keep up; drop rather than block the driver
+	}
+}
+
+// close stops listening, and closes notes so its waiter returns.
+func (n *noteInput) close() error {
+	n.stopListen()
+	n.closeNotes()
+	return n.in.Close()
+}
+
+func (n *noteInput) closeNotes() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.closed {
+		n.closed = true
+		close(n.notes)
+	}
+}
+
+// noteInputMsg carries a note from the MIDI input to the TUI.
+type noteInputMsg struct {
+	input *noteInput
+	note  inputNote
+}
+
+// waitForNote blocks until a note is played on the input, or returns nothing
+// once the input is closed.
+func waitForNote(n *noteInput) tea.Cmd {
+	return func() tea.Msg {
+		note, ok := <-n.notes
+		if !ok {
+			return nil
+		}
+		return noteInputMsg{input: n, note: note}
+	}
+}
+
+// recordTake is one run of recording, from playback starting with a channel
+// armed until it stops. It is undone in one go.
+type recordTake struct {
+	recorded [numSteps]bool // Steps played in this take, which replace mode leaves alone
+	pushed   bool           // Whether the state from before the take is on the undo stack
+}
+
+// selectInput records notes from the given input port, or from none for
+// index -1.
+func (s *sequencerModel) selectInput(index int) (tea.Cmd, error) {
+	if index >= len(s.midiIns) {
+		return nil, fmt.Errorf("invalid port index")
+	}
+	s.closeInput()
+	if index < 0 {
+		s.message = "No MIDI input for notes"
+		return nil, nil
+	}
+
+	n, err := newNoteInput(s.midiIns[index])
+	if err != nil {
+		return nil, err
+	}
+	s.noteIn = n
+	s.message = fmt.Sprintf("Notes from: %s (W arms the current channel)", s.midiInNames[index])
+	return waitForNote(n), nil
+}
+
+// closeInput stops listening for notes.
+func (s *sequencerModel) closeInput() {
+	if s.noteIn != nil {
+		if err := s.noteIn.close(); err != nil {
+			s.message = fmt.Sprintf("Error closing note input: %v", err)
+		}
+		s.noteIn = nil
+	}
+}
+
+// cycleRecord arms the cursor's channel for overdub, then replace, then
+// disarms it. Arming another channel moves the arm there.
+func (s *sequencerModel) cycleRecord() {
+	if s.recording != recordOff && s.armed != s.cursorY {
+		s.recording = recordOff
+	}
+	s.recording = (s.recording + 1) % numRecordModes
+	s.armed = s.cursorY
+	s.take = nil
+	switch {
+	case s.recording == recordOff:
+		s.message = "Recording off"
+	case s.noteIn == nil:
+		s.message = fmt.Sprintf("Ch %d armed (%s): press I to pick a MIDI input", s.armed+1, s.recording)
+	default:
+		s.message = fmt.Sprintf("Ch %d armed (%s): notes played during playback are recorded", s.armed+1, s.recording)
+	}
+}
+
+// recordLabel describes the note input and what it records into.
+func (s *sequencerModel) recordLabel() string {
+	in := "none (press I to pick one)"
+	if s.noteIn != nil {
+		in = s.noteIn.in.String()
+	}
+	rec := "off"
+	if s.recording != recordOff {
+		rec = fmt.Sprintf("Ch %d %s", s.armed+1, s.recording)
+	}
+	return fmt.Sprintf("MIDI In: %s • Record: %s", in, rec)
+}
+
+// recordEdit applies an edit made by recording, leaving the file unsaved. A
+// take is undone in one go, so only the state from before its first edit is
+// pushed onto the history.
+func (s *sequencerModel) recordEdit(edit func()) {
+	h, before := s.history(), s.snapshot(s.slot)
+	edit()
+	if !s.changedSince(before) {
+		return
+	}
+	s.dirty = true
+	if s.take == nil || !s.take.pushed {
+		h.push(before)
+	}
+	if s.take != nil {
+		s.take.pushed = true
+	}
+	s.syncPlayer()
+}
+
+// currentTake returns the take being recorded, starting one if needed.
+func (s *sequencerModel) currentTake() *recordTake {
+	if s.take == nil {
+		s.take = &recordTake{}
+	}
+	return s.take
+}
+
+// updateNoteInput handles a note from the MIDI input while the sequencer is
+// open.
+func (m model) updateNoteInput(msg noteInputMsg) (tea.Model, tea.Cmd) {
+	if m.mode == sequencerMode {
+		m.sequencer.playInput(msg.note)
+	}
+	return m, waitForNote(msg.input)
+}
+
+// playInput passes a note from the MIDI input through to the output. While
+// playing it sounds on the armed channel, or the cursor's, and is recorded on
+// the step nearest to when it was played; otherwise it is entered at the
+// cursor.
+func (s *sequencerModel) playInput(note inputNote) {
+	if note.velocity == 0 {
+		// Release the note on whichever channel it started on
+		if ch := s.thru[note.key]; ch > 0 {
+			s.sendNoteOff(ch-1, note.key)
+			s.thru[note.key] = 0
+		}
+		if s.entryHeld > 0 && !note.tapped {
+			s.entryHeld--
+		}
+		return
+	}
+
+	ch := s.soundInput(note)
+	switch {
+	case s.isPlaying:
+		if s.recording != recordOff && s.player != nil {
+			take, step := s.currentTake(), s.quantize(s.player.tickAt(note.at))
+			s.recordEdit(func() { s.recordNote(ch, step, note) })
+			take.recorded[step] = true
+		}
+	case !s.overlayOpen():
+		s.enterStep(note)
+	}
+}
+
+// soundInput starts a played note on the armed channel while recording, or
+// the cursor's, returning the channel.
+func (s *sequencerModel) soundInput(note inputNote) int {
+	ch := s.cursorY
+	if s.recording != recordOff && s.isPlaying {
+		ch = s.armed
+	}
+	channel := uint8(ch) //nolint:gosec // ch is bounded by numChannels
+	s.sendNoteOn(channel, note.key, note.velocity)
+	s.thru[note.key] = channel + 1
+	return ch
+}
+
+// enterStep writes a note played outside playback into the step under the
+// cursor and moves on to the next, as on a step sequencer. Notes played while
+// an entered key is still held join that step as a chord. Tapped notes have no
+// release to wait for, so each takes a step of its own.
+func (s *sequencerModel) enterStep(note inputNote) {
+	ch := s.cursorY
+	if s.entryHeld > 0 && !note.tapped {
+		s.entryHeld++
+		s.recordEdit(func() { s.addChordNote(ch, s.entryStep, int(note.key)) })
+		return
+	}
+
+	// Each step entered, chord and all, is undone on its own
+	s.take = &recordTake{}
+	step := s.cursorX
+	s.entryHeld, s.entryStep = 1, step
+	if note.tapped {
+		s.entryHeld = 0
+	}
+	s.recordEdit(func() {
+		// The entered note replaces any tied into or out of the step
+		if step > 0 {
+			s.ties[ch][step-1] = false
+		}
+		s.ties[ch][step] = false
+		s.steps[ch][step] = true
+		s.notes[ch][step] = int(note.key)
+		s.chords[ch][step] = nil
+		s.velocities[ch][step] = int(note.velocity)
+	})
+	if s.cursorX < numSteps-1 {
+		s.cursorX++
+	}
+}
+
+// recordNote writes a played note into a step. Notes landing on a step that
+// already plays, in overdub mode or earlier in the same take, join it as a
+// chord; otherwise the step starts afresh with the note.
+func (s *sequencerModel) recordNote(ch, step int, note inputNote) {
+	if s.startsNote(ch, step) && (s.recording == recordOverdub || s.currentTake().recorded[step]) {
+		s.addChordNote(ch, step, int(note.key))
+		return
+	}
+	if s.isTieContinuation(ch, step) {
+		// A new note cuts off the one held into this step
+		s.ties[ch][step-1] = false
+	}
+	s.setCell(ch, step, cell{on: true, note: int(note.key), velocity: int(note.velocity), gate: maxGate, chance: maxChance, ratchet: 1, lanes: s.laneSteps(ch, step)})
+}
+
+// quantize finds the step nearest to a tick of the pattern playing. Notes
+// played just before the loop comes round land on its first step.
+func (s *sequencerModel) quantize(tick uint32) int {
+	first, end := 0, numSteps
+	if s.looping {
+		first, end = s.loopStart, s.loopEnd+1
+	}
+	best, bestDist := first, abs(int(tick)-end*int(ticksPerStep))
+	for step := first; step < end; step++ {
+		if dist := abs(int(tick) - int(s.stepPosition(step))); dist < bestDist {
+			best, bestDist = step, dist
+		}
+	}
+	return best
+}
+
+// erasePassed clears the armed channel's steps after from, up to and
+// including to, as the playhead passes them in replace mode. Steps played in
+// the take stay.
+func (s *sequencerModel) erasePassed(from, to int) {
+	if s.recording != recordReplace || !s.isPlaying {
+		return
+	}
+	if s.take == nil {
+		// A new take clears the step it starts on too
+		from = to - 1
+	}
+	first, last := 0, numSteps-1
+	if s.looping {
+		first, last = s.loopStart, s.loopEnd
+	}
+	take := s.currentTake()
+	ch := s.armed
+	s.recordEdit(func() {
+		// The playhead may have wrapped round to the start of the pattern or loop
+		for step, n := from, 0; step != to && n < numSteps; n++ {
+			if step++; step > last {
+				step = first
+			}
+			if !take.recorded[step] {
+				s.setCell(ch, step, cell{note: s.notes[ch][step], velocity: defaultVelocity, gate: maxGate, chance: maxChance, ratchet: 1, lanes: s.laneSteps(ch, step)})
+			}
+		}
+	})
+}
+
+// updateInputSelection handles keys in the note input list. Entry 0 is no
+// input, followed by the input ports.
+func (s *sequencerModel) updateInputSelection(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case keyUp, "k":
+		if s.inputCursor > 0 {
+			s.inputCursor--
+		}
+	case keyDown, "j":
+		if s.inputCursor < len(s.midiIns) {
+			s.inputCursor++
+		}
+	case "enter":
+		s.selectingInput = false
+		cmd, err := s.selectInput(s.inputCursor - 1)
+		if err != nil {
+			s.message = fmt.Sprintf("Error: %v", err)
+		}
+		return cmd
+	case "esc", "q", "I":
+		s.selectingInput = false
+	case "r":
+		s.refreshMIDIIns()
+		s.message = fmt.Sprintf("Found %d MIDI input(s)", len(s.midiIns))
+	}
+	return nil
+}
+
+func (m model) viewInputSelection() string {
+	s := m.sequencer
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Select MIDI Input for Notes") + "\n\n")
+
+	entries := append([]string{"None"}, s.midiInNames...)
+	for i, name := range entries {
+		cursor := "  "
+		if i == s.inputCursor {
+			cursor = "> "
+		}
+
+		// Mark the input currently in use
+		active := ""
+		if (i == 0 && s.noteIn == nil) || (i > 0 && s.noteIn != nil && s.noteIn.in.String() == name) {
+			active = " (active)"
+		}
+
+		if i == s.inputCursor {
+			b.WriteString(selectedStyle.Render(fmt.Sprintf("%s%s%s\n", cursor, name, active)))
+		} else {
+			b.WriteString(fmt.Sprintf("%s%s%s\n", cursor, name, active))
+		}
+	}
+	if len(s.midiInNames) == 0 {
+		b.WriteString("\nNo MIDI input ports found.\n")
+	}
+
+	b.WriteString("\n")
+	if s.message != "" {
+		b.WriteString(errorStyle.Render(s.message) + "\n")
+	}
+
+	b.WriteString("\n" + helpStyle.Render("↑/k: up • ↓/j: down • enter: select • r: refresh • q/esc: cancel"))
+
+	return b.String()
+}
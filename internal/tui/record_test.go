@@ -0,0 +1,200 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gitlab.com/gomidi/midi/v2"
+)
+
+func TestQuantizeToNearestStep(t *testing.T) {
+	s := &sequencerModel{swing: 75}
+	for _, tt := range []struct {
+		tick uint32
+		want int
+	}{
+		{0, 0},
+		{ticksPerStep / 2, 0},       // Step 1 is swung late, so step 0 is nearer
+		{ticksPerStep + 100, 1},     // Close to the swung step 1
+		{patternTicks - 20, 0},      // Just before the bar comes round
+		{15*ticksPerStep + 130, 15}, // Late on the last step, which is swung too
+	} {
+		if got := s.quantize(tt.tick); got != tt.want {
+			t.Errorf("Expected tick %d on step %d, got %d", tt.tick, tt.want, got)
+		}
+	}
+
+	s = &sequencerModel{swing: minSwing, looping: true, loopStart: 4, loopEnd: 7}
+	if got := s.quantize(8*ticksPerStep - 10); got != 4 {
+		t.Errorf("Expected a note just before the loop comes round on its first step, got %d", got)
+	}
+}
+
+// recordingModel is a sequencer playing at 120 BPM with channel 2 armed,
+// whose scheduler last played the top of the bar at start.
+func recordingModel(t *testing.T, mode recordMode) (*sequencerModel, time.Time) {
+	t.Helper()
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(filepath.Join(t.TempDir(), "rec.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.cursorY = 1
+	for s.recording != mode {
+		s.cycleRecord()
+	}
+	s.isPlaying = true
+	s.player = newScheduler(s.playbackState(), 0, 0)
+	start := time.Now()
+	s.player.lastTime = start
+	return s, start
+}
+
+func TestRecordOverdub(t *testing.T) {
+	s, start := recordingModel(t, recordOverdub)
+	var sent []midi.Message
+	s.sendFunc = func(msg midi.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	s.steps[1][4] = true
+	s.notes[1][4] = 48
+
+	step := ticksToDuration(uint64(ticksPerStep), s.bpm)
+	s.cursorY = 0 // Recording goes to the armed channel wherever the cursor is
+	s.playInput(inputNote{key: 64, velocity: 90, at: start.Add(step + step/4)})
+	s.playInput(inputNote{key: 67, velocity: 80, at: start.Add(step)})
+	s.playInput(inputNote{key: 72, velocity: 70, at: start.Add(4*step - step/4)})
+	s.playInput(inputNote{key: 64, at: start.Add(2 * step)})
+
+	if !s.steps[1][1] || s.notes[1][1] != 64 || s.velocities[1][1] != 90 || !slices.Equal(s.chords[1][1], []int{3}) {
+		t.Errorf("Expected E4 and G4 together on step 1, got %s", s.chordName(1, 1))
+	}
+	if got := s.chordName(1, 4); got != "C3 +24" {
+		t.Errorf("Expected C5 added to the C3 already on step 4, got %s", got)
+	}
+	if len(sent) != 4 || !sent[0].Is(midi.NoteOnMsg) || !sent[3].Is(midi.NoteOffMsg) {
+		t.Errorf("Expected the notes to be played through, got %v", sent)
+	}
+
+	// The take is undone in one go
+	s.undo()
+	if s.steps[1][1] || s.chordName(1, 4) != "C3" {
+		t.Errorf("Expected undo to take back the whole take, got %s and %s", s.chordName(1, 1), s.chordName(1, 4))
+	}
+}
+
+func TestRecordReplace(t *testing.T) {
+	s, start := recordingModel(t, recordReplace)
+	for step := range numSteps {
+		s.steps[1][step] = true
+	}
+
+	// Playback starts on step 0, a note lands on step 2 and the playhead
+	// moves on to step 3
+	s.erasePassed(0, 0)
+	s.playInput(inputNote{key: 60, velocity: 100, at: start.Add(ticksToDuration(uint64(2*ticksPerStep), s.bpm))})
+	s.erasePassed(0, 3)
+
+	var on []int
+	for step, active := range s.steps[1] {
+		if active {
+			on = append(on, step)
+		}
+	}
+	if want := []int{2, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}; !slices.Equal(on, want) {
+		t.Errorf("Expected steps %v to play, got %v", want, on)
+	}
+
+	// Wrapping round erases the rest of the bar and the start of the next
+	s.erasePassed(3, 1)
+	if !s.steps[1][2] || s.steps[1][15] || s.steps[1][1] {
+		t.Errorf("Expected only the recorded step left, got %v", s.steps[1])
+	}
+}
+
+func TestClosedInputReleasesWaiter(t *testing.T) {
+	n := &noteInput{notes: make(chan inputNote, 64)}
+	done := make(chan tea.Msg)
+	go func() { done <- waitForNote(n)() }()
+
+	n.closeNotes()
+	select {
+	case msg := <-done:
+		if msg != nil {
+			t.Errorf("Expected nothing from a closed input, got %v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the waiter to return once the input closed")
+	}
+
+	// A note arriving late is dropped rather than sent on the closed channel
+	n.receive(midi.NoteOn(0, 60, 100), 0)
+}
+
+func TestRecordKeys(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "rec.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	m = pressKeys(m, "j", "W")
+	if s := m.sequencer; s.recording != recordOverdub || s.armed != 1 {
+		t.Fatalf("Expected channel 2 armed to overdub, got %s on %d", s.recording, s.armed+1)
+	}
+	m = pressKeys(m, "W", "W")
+	if m.sequencer.recording != recordOff {
+		t.Errorf("Expected a third press to disarm, got %s", m.sequencer.recording)
+	}
+
+	// Arming stays off the undo stack
+	m = pressKeys(m, "W", "u")
+	if m.sequencer.recording != recordOverdub || m.sequencer.message != "Nothing to undo" {
+		t.Errorf("Expected arming not to be an edit, got %q", m.sequencer.message)
+	}
+}
+
+func TestStepEntry(t *testing.T) {
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(filepath.Join(t.TempDir(), "entry.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.cursorX, s.cursorY = 14, 2
+	s.ties[2][13] = true
+	s.steps[2][13] = true
+
+	// A note, then a chord played before its keys come up
+	s.playInput(inputNote{key: 62, velocity: 90})
+	s.playInput(inputNote{key: 62})
+	s.playInput(inputNote{key: 60, velocity: 70})
+	s.playInput(inputNote{key: 64, velocity: 70})
+	s.playInput(inputNote{key: 60})
+	s.playInput(inputNote{key: 67, velocity: 70})
+
+	if !s.steps[2][14] || s.notes[2][14] != 62 || s.velocities[2][14] != 90 || s.ties[2][13] {
+		t.Errorf("Expected D4 entered on step 14 with the tie into it cut, got %s", s.chordName(2, 14))
+	}
+	if got := s.chordName(2, 15); got != "C4 maj" {
+		t.Errorf("Expected the held notes to make a chord on step 15, got %s", got)
+	}
+	if s.cursorX != numSteps-1 {
+		t.Errorf("Expected the cursor to stop on the last step, got %d", s.cursorX)
+	}
+
+	// Each step is undone on its own
+	s.undo()
+	if s.steps[2][15] || !s.steps[2][14] {
+		t.Errorf("Expected undo to take back only the chord, got %s", s.chordName(2, 15))
+	}
+
+	// During playback notes are not entered without a channel armed
+	s.isPlaying = true
+	s.cursorX = 3
+	s.playInput(inputNote{key: 72, velocity: 100})
+	if s.steps[2][3] || s.cursorX != 3 {
+		t.Error("Expected nothing entered while playing")
+	}
+}
@@ -0,0 +1,181 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// autosaveIntervals are the autosave settings cycled through, off first.
+var autosaveIntervals = []time.Duration{0, 30 * time.Second, time.Minute, 5 * time.Minute}
+
+// autosaveLabel describes an autosave interval for the status line.
+func autosaveLabel(interval time.Duration) string {
+	switch {
+	case interval == 0:
+		return "off"
+	case interval%time.Minute == 0:
+		return fmt.Sprintf("every %dm", interval/time.Minute)
+	default:
+		return fmt.Sprintf("every %ds", interval/time.Second)
+	}
+}
+
+// recoveryPath is where unsaved changes to a file are autosaved. The leading
+// dot keeps it out of the file browser.
+func recoveryPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".recovery")
+}
+
+// save writes the file and forgets any autosaved recovery copy.
+func (s *sequencerModel) save() error {
+	if err := s.saveMIDI(); err != nil {
+		return err
+	}
+	s.dirty = false
+	s.discardRecovery(s.filePath)
+	return nil
+}
+
+// writeRecovery autosaves unsaved changes next to the file, leaving the file
+// itself alone.
+func (s *sequencerModel) writeRecovery() {
+	if !s.dirty || s.filePath == "" {
+		return
+	}
+	if err := s.writeMIDI(recoveryPath(s.filePath)); err != nil {
+		s.message = fmt.Sprintf("Error autosaving: %v", err)
+	}
+}
+
+// discardRecovery deletes the recovery copy of a file, if there is one.
+func (s *sequencerModel) discardRecovery(path string) {
+	if err := os.Remove(recoveryPath(path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		s.message = fmt.Sprintf("Error removing recovery file: %v", err)
+	}
+}
+
+// autosaveMsg fires when an autosave is due. Ticks from an earlier setting
+// carry a stale generation and are dropped.
+type autosaveMsg struct {
+	gen int
+}
+
+func autosaveTick(interval time.Duration, gen int) tea.Cmd {
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return autosaveMsg{gen: gen}
+	})
+}
+
+// cycleAutosave moves on to the next autosave interval.
+func (s *sequencerModel) cycleAutosave() tea.Cmd {
+	i := 0
+	for j, interval := range autosaveIntervals {
+		if interval == s.autosave {
+			i = j
+		}
+	}
+	s.autosave = autosaveIntervals[(i+1)%len(autosaveIntervals)]
+	s.autosaveGen++
+
+	if s.autosave == 0 {
+		s.message = "Autosave off"
+		return nil
+	}
+	s.message = fmt.Sprintf("Autosaving unsaved changes %s to %s", autosaveLabel(s.autosave), filepath.Base(recoveryPath(s.filePath)))
+	return autosaveTick(s.autosave, s.autosaveGen)
+}
+
+// handleAutosave writes the recovery file if needed and schedules the next
+// autosave.
+func (s *sequencerModel) handleAutosave(msg autosaveMsg) tea.Cmd {
+	if msg.gen != s.autosaveGen || s.autosave == 0 {
+		return nil
+	}
+	s.writeRecovery()
+	return autosaveTick(s.autosave, s.autosaveGen)
+}
+
+// openSaveAs asks for a new file name, starting from the current one.
+func (s *sequencerModel) openSaveAs() {
+	s.saveAs = &textPrompt{title: "Save As", value: filepath.Base(s.filePath)}
+}
+
+// updateSaveAs handles keys in the save-as prompt. An existing file is only
+// overwritten after a second enter.
+func (s *sequencerModel) updateSaveAs(msg tea.KeyMsg) {
+	p := s.saveAs
+	submitted, cancelled := p.update(msg)
+	if cancelled {
+		s.saveAs = nil
+		return
+	}
+	if !submitted {
+		return
+	}
+
+	path, err := midiFilePath(filepath.Dir(s.filePath), p.value)
+	if err != nil {
+		p.err = err.Error()
+		return
+	}
+	if _, err := os.Stat(path); err == nil && path != s.filePath && p.warned != path {
+		p.warned = path
+		p.err = fmt.Sprintf("%s already exists: press enter again to overwrite it", filepath.Base(path))
+		return
+	}
+
+	old := s.filePath
+	s.filePath = path
+	if err := s.save(); err != nil {
+		s.filePath = old
+		p.err = err.Error()
+		return
+	}
+	// The changes now live in the new file, along with their history
+	s.discardRecovery(old)
+	if h, ok := s.histories[old]; ok {
+		s.histories[path] = h
+		delete(s.histories, old)
+	}
+	s.saveAs = nil
+	s.message = fmt.Sprintf("Saved as %s", filepath.Base(path))
+}
+
+// updateConfirmLeave handles keys while asking what to do with unsaved
+// changes before going back to the file browser. It reports whether to leave.
+func (s *sequencerModel) updateConfirmLeave(msg tea.KeyMsg) bool {
+	switch strings.ToLower(msg.String()) {
+	case "y", "s":
+		s.confirmingLeave = false
+		if err := s.save(); err != nil {
+			s.message = fmt.Sprintf("Error saving: %v", err)
+			return false
+		}
+		return true
+	case "n", "d":
+		s.confirmingLeave = false
+		s.dirty = false
+		s.discardRecovery(s.filePath)
+		return true
+	case "esc", "c":
+		s.confirmingLeave = false
+	}
+	return false
+}
+
+func (m model) viewConfirmLeave() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Unsaved Changes") + "\n\n")
+	fmt.Fprintf(&b, "%s has unsaved changes.\n", filepath.Base(m.sequencer.filePath))
+	b.WriteString("\n" + helpStyle.Render("s: save and leave • d: discard changes and leave • esc: keep editing"))
+
+	return b.String()
+}
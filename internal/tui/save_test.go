@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestEditsWaitForExplicitSave(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "dirty.mid")
+
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	m = pressKeys(m, "l")
+	if m.sequencer.dirty {
+		t.Error("Expected moving the cursor to leave the file clean")
+	}
+	m = pressKeys(m, " ")
+	if !m.sequencer.dirty {
+		t.Fatal("Expected toggling a step to mark the file dirty")
+	}
+
+	// Nothing reaches the file until it is saved
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if s2.steps[0][1] {
+		t.Error("Expected the edit not to be written before saving")
+	}
+
+	next, _ := m.updateSequencer(tea.KeyMsg{Type: tea.KeyCtrlS})
+	m = next.(model)
+	if m.sequencer.dirty {
+		t.Error("Expected saving to clear the dirty flag")
+	}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if !s2.steps[0][1] {
+		t.Error("Expected the edit to be written after saving")
+	}
+}
+
+func TestLeavingWithUnsavedChangesAsks(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "leave.mid")
+
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	m = pressKeys(m, " ")
+
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	m = next.(model)
+	if m.mode != sequencerMode || !m.sequencer.confirmingLeave {
+		t.Fatal("Expected to be asked about unsaved changes")
+	}
+
+	m = pressKeys(m, "esc")
+	if m.mode != sequencerMode || m.sequencer.confirmingLeave {
+		t.Fatal("Expected esc to keep editing")
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	m = pressKeys(next.(model), "s")
+	if m.mode != fileBrowserMode {
+		t.Fatal("Expected to leave after saving")
+	}
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if !s2.steps[0][0] {
+		t.Error("Expected the edit to be saved on the way out")
+	}
+}
+
+func TestSaveAs(t *testing.T) {
+	dir := t.TempDir()
+	testPath := filepath.Join(dir, "first.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "taken.mid"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s.steps[0][0] = true
+	s.dirty = true
+
+	s.openSaveAs()
+	s.saveAs.value = "taken"
+	s.updateSaveAs(tea.KeyMsg{Type: tea.KeyEnter})
+	if s.saveAs == nil || s.saveAs.err == "" {
+		t.Fatal("Expected a warning before overwriting an existing file")
+	}
+	s.saveAs.value = "second"
+	s.updateSaveAs(tea.KeyMsg{Type: tea.KeyEnter})
+	if s.saveAs != nil {
+		t.Fatalf("Expected the prompt to close, got error %q", s.saveAs.err)
+	}
+	if want := filepath.Join(dir, "second.mid"); s.filePath != want || s.dirty {
+		t.Errorf("Expected a clean %s, got %s (dirty %v)", want, s.filePath, s.dirty)
+	}
+	if _, err := os.Stat(s.filePath); err != nil {
+		t.Errorf("Expected the new file to be written: %v", err)
+	}
+}
+
+func TestRecoveryFileRestoresUnsavedChanges(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "crash.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.steps[2][3] = true
+	s.dirty = true
+	s.writeRecovery()
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if !s2.steps[2][3] || !s2.dirty {
+		t.Fatal("Expected the autosaved edit to load as an unsaved change")
+	}
+
+	if err := s2.save(); err != nil {
+		t.Fatalf("Error saving: %v", err)
+	}
+	if _, err := os.Stat(recoveryPath(testPath)); !os.IsNotExist(err) {
+		t.Error("Expected saving to remove the recovery file")
+	}
+}
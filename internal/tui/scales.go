@@ -0,0 +1,338 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// noteNames are the pitch classes from C.
+var noteNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// parsePitchClass reads a note letter with an optional sharp or flat from the
+// start of name, returning the pitch class and what follows it.
+func parsePitchClass(name string) (pc int, rest string, ok bool) {
+	if name == "" {
+		return 0, "", false
+	}
+	// Letters sit at their semitone above C
+	pc = strings.IndexByte("C D EF G A B", strings.ToUpper(name)[0])
+	if pc < 0 || name[0] == ' ' {
+		return 0, "", false
+	}
+	rest = name[1:]
+	if strings.HasPrefix(rest, "#") {
+		pc, rest = pc+1, rest[1:]
+	} else if strings.HasPrefix(rest, "b") {
+		pc, rest = pc-1, rest[1:]
+	}
+	return pc, rest, true
+}
+
+// parseNoteName reads a note written the way midiNoteToName writes it, such
+// as "C3" or "F#-1", or a MIDI note number. Flats are accepted too.
+func parseNoteName(name string) (int, error) {
+	note, err := strconv.Atoi(name)
+	if err != nil {
+		pc, rest, ok := parsePitchClass(name)
+		octave, err := strconv.Atoi(rest)
+		if !ok || err != nil {
+			return 0, fmt.Errorf("invalid note %q: use a name like C3 or F#4, or a MIDI note number", name)
+		}
+		note = (octave+1)*notesPerOctave + pc
+	}
+	if note < minMIDINote || note > maxMIDINote {
+		return 0, fmt.Errorf("note %q is outside the MIDI range C-1 to G9", name)
+	}
+	return note, nil
+}
+
+// parseRoot reads a tonic such as "D" or "Bb" as a pitch class.
+func parseRoot(name string) (int, error) {
+	pc, rest, ok := parsePitchClass(name)
+	if !ok || rest != "" {
+		return 0, fmt.Errorf("invalid root %q: use a note name like C, F# or Bb", name)
+	}
+	return (pc + notesPerOctave) % notesPerOctave, nil
+}
+
+// scaleIndex finds a scale in scaleTypes by name. The custom scale has no
+// degrees until they are chosen, so it can't be named.
+func scaleIndex(name string) (int, error) {
+	for i, st := range scaleTypes {
+		if i != scaleCustom && strings.EqualFold(st.name, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scale %q: choose from %s", name, strings.Join(ScaleNames(), ", "))
+}
+
+// ScaleNames lists the scales that can be named on the command line.
+func ScaleNames() []string {
+	var names []string
+	for _, st := range scaleTypes[:scaleCustom] {
+		names = append(names, st.name)
+	}
+	return names
+}
+
+// scaleType is a named set of semitones above the root.
+type scaleType struct {
+	name    string
+	degrees []int
+}
+
+// scaleTypes lists the scales on offer. Chromatic leaves notes unlocked and
+// the custom scale's degrees are chosen by the user.
+var scaleTypes = []scaleType{
+	{"chromatic", []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+	{"major", []int{0, 2, 4, 5, 7, 9, 11}},
+	{"minor", []int{0, 2, 3, 5, 7, 8, 10}},
+	{"dorian", []int{0, 2, 3, 5, 7, 9, 10}},
+	{"phrygian", []int{0, 1, 3, 5, 7, 8, 10}},
+	{"lydian", []int{0, 2, 4, 6, 7, 9, 11}},
+	{"mixolydian", []int{0, 2, 4, 5, 7, 9, 10}},
+	{"locrian", []int{0, 1, 3, 5, 6, 8, 10}},
+	{"harmonic minor", []int{0, 2, 3, 5, 7, 8, 11}},
+	{"major pentatonic", []int{0, 2, 4, 7, 9}},
+	{"minor pentatonic", []int{0, 3, 5, 7, 10}},
+	{"blues", []int{0, 3, 5, 6, 7, 10}},
+	{"custom", nil},
+}
+
+// Scales with special handling, by index into scaleTypes
+const (
+	scaleChromatic = 0
+	scaleMajor     = 1
+)
+
+var scaleCustom = len(scaleTypes) - 1
+
+// outOfScaleColor marks steps whose notes fall outside the scale.
+var outOfScaleColor = lipgloss.Color("#FF8800")
+
+// scaleLock is the project's key. Notes stepped with w/s stay in it.
+type scaleLock struct {
+	root   int      // Pitch class of the tonic, 0 for C
+	scale  int      // Index into scaleTypes
+	custom [12]bool // Semitones above the root in the custom scale
+}
+
+// scaleEditor is the key being chosen in the overlay. It only replaces the
+// project's key on enter.
+type scaleEditor struct {
+	scaleLock
+	cursor int // Custom degree highlighted
+}
+
+// locked reports whether notes are held to a scale.
+func (k *scaleLock) locked() bool {
+	return k.scale != scaleChromatic
+}
+
+// degrees returns the semitones above the root that are in the scale.
+func (k *scaleLock) degrees() []int {
+	if k.scale != scaleCustom {
+		return scaleTypes[k.scale].degrees
+	}
+	var degrees []int
+	for i, on := range k.custom {
+		if on {
+			degrees = append(degrees, i)
+		}
+	}
+	return degrees
+}
+
+// name describes the key, e.g. "D dorian".
+func (k *scaleLock) name() string {
+	if !k.locked() {
+		return "off"
+	}
+	return noteNames[k.root] + " " + scaleTypes[k.scale].name
+}
+
+// inScale reports whether a note belongs to the scale. Every note does when
+// the scale is chromatic or an empty custom scale.
+func (k *scaleLock) inScale(note int) bool {
+	degrees := k.degrees()
+	return !k.locked() || len(degrees) == 0 || slices.Contains(degrees, ((note-k.root)%12+12)%12)
+}
+
+// step returns the next note in the scale above (dir 1) or below (dir -1)
+// note, staying at note when there is none in the MIDI range.
+func (k *scaleLock) step(note, dir int) int {
+	for n := note + dir; n >= 0 && n <= maxMIDINote; n += dir {
+		if k.inScale(n) {
+			return n
+		}
+	}
+	return note
+}
+
+// quantize returns the scale note nearest to note, preferring the one below
+// on a tie.
+func (k *scaleLock) quantize(note int) int {
+	if k.inScale(note) {
+		return note
+	}
+	down, up := k.step(note, -1), k.step(note, 1)
+	if !k.inScale(down) || (k.inScale(up) && up-note < note-down) {
+		return up
+	}
+	return down
+}
+
+// stepNoteInScale moves the cursor's note by a semitone, or by a scale degree
+// when the scale is locked.
+func (s *sequencerModel) stepNoteInScale(dir int) {
+	note := &s.notes[s.cursorY][s.cursorX]
+	if s.key.locked() {
+		*note = s.key.step(*note, dir)
+		return
+	}
+	*note = min(max(*note+dir, 0), maxMIDINote)
+}
+
+// stepInScale reports whether all of a step's notes are in the scale.
+func (s *sequencerModel) stepInScale(ch, step int) bool {
+	for _, note := range s.stepNotes(ch, step) {
+		if !s.key.inScale(int(note)) {
+			return false
+		}
+	}
+	return true
+}
+
+// quantizeStep moves a step's root and chord notes onto the scale. Chord
+// notes that land on the same pitch merge.
+func (s *sequencerModel) quantizeStep(ch, step int) {
+	root := s.key.quantize(s.notes[ch][step])
+	var intervals []int
+	for _, interval := range s.chords[ch][step] {
+		if i := s.key.quantize(s.notes[ch][step]+interval) - root; i > 0 && !slices.Contains(intervals, i) {
+			intervals = append(intervals, i)
+		}
+	}
+	slices.Sort(intervals)
+	s.notes[ch][step] = root
+	s.chords[ch][step] = intervals
+}
+
+// quantizeNotes moves the notes of the selection, or of the whole pattern,
+// onto the scale.
+func (s *sequencerModel) quantizeNotes() {
+	if !s.key.locked() {
+		s.message = "No scale set: press K to choose a key"
+		return
+	}
+	x0, y0, x1, y1 := 0, 0, numSteps-1, numChannels-1
+	if s.selecting {
+		x0, y0, x1, y1 = s.selection()
+		s.selecting = false
+	}
+	moved := 0
+	for ch := y0; ch <= y1; ch++ {
+		for step := x0; step <= x1; step++ {
+			if !s.stepInScale(ch, step) {
+				s.quantizeStep(ch, step)
+				if s.steps[ch][step] {
+					moved++
+				}
+			}
+		}
+	}
+	s.message = fmt.Sprintf("Quantized %d step(s) to %s", moved, s.key.name())
+}
+
+// openScale starts the key overlay from the current setting.
+// A custom scale starts out as the major scale.
+func (s *sequencerModel) openScale() {
+	s.scaleEdit = &scaleEditor{scaleLock: s.key}
+	if !slices.Contains(s.key.custom[:], true) {
+		for _, d := range scaleTypes[scaleMajor].degrees {
+			s.scaleEdit.custom[d] = true
+		}
+	}
+}
+
+// updateScale handles keys in the key overlay.
+func (s *sequencerModel) updateScale(msg tea.KeyMsg) {
+	k := s.scaleEdit
+	switch msg.String() {
+	case keyUp, "k":
+		k.scale = (k.scale + len(scaleTypes) - 1) % len(scaleTypes)
+	case keyDown, "j":
+		k.scale = (k.scale + 1) % len(scaleTypes)
+	case keyLeft, "h":
+		k.root = (k.root + 11) % 12
+	case keyRight, "l":
+		k.root = (k.root + 1) % 12
+	case ",":
+		k.cursor = (k.cursor + 11) % 12
+	case ".":
+		k.cursor = (k.cursor + 1) % 12
+	case " ":
+		if k.scale == scaleCustom {
+			k.custom[k.cursor] = !k.custom[k.cursor]
+		}
+	case "enter":
+		if k.scale != scaleCustom {
+			// Only a custom scale keeps its degrees
+			k.custom = [12]bool{}
+		}
+		s.key = k.scaleLock
+		s.scaleEdit = nil
+		s.message = "Scale: " + s.key.name()
+	case "esc", "q", "K":
+		s.scaleEdit = nil
+	}
+}
+
+func (m model) viewScale() string {
+	k := m.sequencer.scaleEdit
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Scale Lock") + "\n\n")
+	fmt.Fprintf(&b, "Root: %s (←/→ to change)\n\n", noteNames[k.root])
+
+	for i, st := range scaleTypes {
+		line := st.name
+		if i != scaleChromatic && i != scaleCustom {
+			var names []string
+			for _, d := range st.degrees {
+				names = append(names, noteNames[(k.root+d)%12])
+			}
+			line = fmt.Sprintf("%-17s %s", st.name, strings.Join(names, " "))
+		}
+		if i == k.scale {
+			b.WriteString(selectedStyle.Render("> "+line) + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+
+	if k.scale == scaleCustom {
+		b.WriteString("\nCustom degrees: ")
+		for i, on := range k.custom {
+			cellStyle := lipgloss.NewStyle().Width(3).Align(lipgloss.Center).Foreground(lipgloss.Color("#666666"))
+			if on {
+				cellStyle = cellStyle.Foreground(lipgloss.Color("#00FF00")).Bold(true)
+			}
+			if i == k.cursor {
+				cellStyle = cellStyle.Background(lipgloss.Color("#5A3DBF"))
+			}
+			b.WriteString(cellStyle.Render(noteNames[(k.root+i)%12]))
+		}
+		b.WriteString("\n" + helpStyle.Render(",/.: choose degree • space: toggle degree"))
+	}
+
+	b.WriteString("\n\n" + helpStyle.Render("↑/↓: scale • ←/→: root • enter: keep • esc: cancel"))
+
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScaleLock(t *testing.T) {
+	dMinor := scaleLock{root: 2, scale: 2} // D E F G A Bb C
+	for _, tt := range []struct {
+		note      int
+		in        bool
+		up, down  int
+		quantized int
+	}{
+		{62, true, 64, 60, 62},  // D4
+		{65, true, 67, 64, 65},  // F4
+		{66, false, 67, 65, 65}, // F#4 is a semitone from both F and G; ties go down
+		{71, false, 72, 70, 70}, // B4 is between Bb and C; ties go down
+		{61, false, 62, 60, 60}, // C#4
+	} {
+		if got := dMinor.inScale(tt.note); got != tt.in {
+			t.Errorf("Expected inScale(%s) to be %v", midiNoteToName(tt.note), tt.in)
+		}
+		if got := dMinor.step(tt.note, 1); got != tt.up {
+			t.Errorf("Expected %s up to be %s, got %s", midiNoteToName(tt.note), midiNoteToName(tt.up), midiNoteToName(got))
+		}
+		if got := dMinor.step(tt.note, -1); got != tt.down {
+			t.Errorf("Expected %s down to be %s, got %s", midiNoteToName(tt.note), midiNoteToName(tt.down), midiNoteToName(got))
+		}
+		if got := dMinor.quantize(tt.note); got != tt.quantized {
+			t.Errorf("Expected %s to quantize to %s, got %s", midiNoteToName(tt.note), midiNoteToName(tt.quantized), midiNoteToName(got))
+		}
+	}
+
+	// Nothing is out of scale when unlocked
+	if off := (scaleLock{}); !off.inScale(61) || off.step(61, 1) != 62 {
+		t.Error("Expected the chromatic scale to hold every note")
+	}
+}
+
+func TestScaleLockKeys(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "scale.mid")
+
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	// Pick C major pentatonic; nothing changes until enter
+	m = pressKeys(m, "K")
+	for range 9 {
+		m = pressKeys(m, "j")
+	}
+	if m.sequencer.key.locked() {
+		t.Fatal("Expected the key to wait for enter")
+	}
+	m = pressKeys(m, "enter")
+	if got := m.sequencer.key.name(); got != "C major pentatonic" {
+		t.Fatalf("Expected C major pentatonic, got %s", got)
+	}
+
+	// w steps from C4 to D4 to E4 to G4
+	m = pressKeys(m, "w", "w", "w")
+	if got := m.sequencer.notes[0][0]; got != 67 {
+		t.Errorf("Expected G4, got %s", midiNoteToName(got))
+	}
+
+	// Quantize moves notes and chord notes into the scale
+	s := &m.sequencer
+	s.steps[1][0] = true
+	s.notes[1][0] = 65           // F4
+	s.chords[1][0] = []int{3, 7} // Ab4 C5
+	m = pressKeys(m, "Q")
+	s = &m.sequencer
+	if s.notes[1][0] != 64 || !slices.Equal(s.chords[1][0], []int{3, 8}) {
+		t.Errorf("Expected E4 G4 C5, got %s", s.chordName(1, 0))
+	}
+	if !s.stepInScale(1, 0) {
+		t.Error("Expected the quantized step to be in the scale")
+	}
+
+	// The key is saved with the project
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if s2.key != s.key {
+		t.Errorf("Expected %s to be restored, got %s", s.key.name(), s2.key.name())
+	}
+}
+
+func TestCustomScaleIsSaved(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "custom.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.key = scaleLock{root: 9, scale: scaleCustom}
+	s.key.custom[0], s.key.custom[1], s.key.custom[4] = true, true, true
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if s2.key != s.key || !s2.key.inScale(70) || s2.key.inScale(71) {
+		t.Errorf("Expected the custom scale A Bb C# to be restored, got %v", s2.key.degrees())
+	}
+}
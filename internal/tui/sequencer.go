@@ -2,11 +2,19 @@ package tui
 
 import (
 	"fmt"
+	"math"
+	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/icco/genidi/internal/arp"
+	"github.com/icco/genidi/internal/audio"
+	"github.com/icco/genidi/internal/piano"
+	"github.com/icco/genidi/internal/tempo"
 	"gitlab.com/gomidi/midi/v2"
 	"gitlab.com/gomidi/midi/v2/drivers"
 	"gitlab.com/gomidi/midi/v2/smf"
@@ -18,21 +26,57 @@ const (
 	numSteps            = 16
 	numChannels         = 4
 	ticksPerQuarterNote = 960 // Standard MIDI resolution
+	minBPM              = tempo.MinBPM
+	maxBPM              = tempo.MaxBPM
 	minMIDINote         = 0   // Minimum MIDI note value
 	maxMIDINote         = 127 // Maximum MIDI note value
 	notesPerOctave      = 12  // Number of notes in an octave
+	defaultVelocity     = 100 // Velocity for newly created steps
+	minVelocity         = 1   // Lowest velocity that still sounds a note
+	maxVelocity         = 127 // Maximum MIDI velocity value
+	velocityIncrement   = 8   // Amount e/d change the velocity by
+	minGate             = 5   // Shortest gate, as a percentage of a step
+	maxGate             = 100 // Full-length gate
+	gateIncrement       = 5   // Amount [/] change the gate by
+	minSwing            = 50  // Straight 16ths
+	maxSwing            = 75  // Off-beats halfway to the next on-beat
+	swingIncrement      = 4   // Amount {/} change the swing by
+	maxNudge            = 50  // Largest micro-timing nudge, as a percentage of a step
+	nudgeIncrement      = 5   // Amount </> nudge a step by
 )
 
+// pattern is one bar of step data. The sequencer edits one pattern at a time
+// and keeps the rest in its bank.
+type pattern struct {
+	steps      [numChannels][numSteps]bool          // Which steps are active
+	notes      [numChannels][numSteps]int           // MIDI note number for each step
+	velocities [numChannels][numSteps]int           // MIDI velocity for each step
+	gates      [numChannels][numSteps]int           // Gate length as a percentage of a step
+	ties       [numChannels][numSteps]bool          // Whether a step's note holds into the next step
+	chords     [numChannels][numSteps][]int         // Extra chord notes as semitone intervals above notes
+	nudges     [numChannels][numSteps]int           // Micro-timing offset as a percentage of a step
+	chances    [numChannels][numSteps]int           // Percentage chance of a step playing on each pass
+	conditions [numChannels][numSteps]trigCondition // Which passes a step plays on
+	ratchets   [numChannels][numSteps]int           // Repeats a step is split into
+	lanes      [numChannels][numLanes]ccLane        // CC automation and parameter locks
+}
+
 // sequencerModel manages the MIDI sequencer state
 type sequencerModel struct {
 	filePath    string
 	bpm         int
-	steps       [numChannels][numSteps]bool // Which steps are active
-	notes       [numChannels][numSteps]int  // MIDI note number for each step
-	cursorX     int                         // Current step
-	cursorY     int                         // Current channel
+	pattern         // The pattern being edited
+	swing       int // Percentage swing applied to off-beat 16ths
+	cursorX     int // Current step
+	cursorY     int // Current channel
 	isPlaying   bool
+	isPaused    bool // Stopped with the playhead kept at currentStep
 	currentStep int
+	player      *scheduler // Plays the pattern while isPlaying
+	looping     bool       // Whether playback loops loopStart-loopEnd instead of the pattern
+	markingLoop bool       // Whether loopStart is set and loopEnd is awaited
+	loopStart   int
+	loopEnd     int
 	message     string
 
 	// MIDI output
@@ -41,8 +85,98 @@ type sequencerModel struct {
 	selectedOut   int                          // Currently selected output index (-1 = none)
 	outPort       drivers.Out                  // Currently open output port
 	sendFunc      func(msg midi.Message) error // Function to send MIDI
+	closeOut      func() error                 // Closes outPort once in-flight sends finish
 	selectingPort bool                         // Whether we're in port selection mode
 
+	// Clock sync
+	midiIns       []drivers.In   // Available MIDI input ports
+	midiInNames   []string       // Names of available input ports
+	follower      *clockFollower // External clock being followed (nil = internal clock)
+	selectingSync bool           // Whether we're in clock source selection mode
+	syncCursor    int            // Highlighted clock source (0 = internal)
+
+	// Live recording
+	noteIn         *noteInput  // MIDI input notes are played and recorded from (nil = none)
+	selectingInput bool        // Whether we're in note input selection mode
+	inputCursor    int         // Highlighted note input (0 = none)
+	recording      recordMode  // What recording does to the armed channel
+	armed          int         // Channel notes are recorded into, unless recording is off
+	take           *recordTake // Recording in progress, if any
+	thru           [128]uint8  // Channel plus one each input note sounds on, zero when released
+	entryHeld      int         // Keys still held from step entry; notes played meanwhile join entryStep
+	entryStep      int         // Step the last note was entered on outside playback
+
+	// Computer keyboard piano
+	piano        *piano.Keyboard // Octave, velocity and sounding notes, kept between uses
+	auditioning  bool            // Piano notes sound without being entered or recorded
+	playingPiano bool            // Whether keys play notes instead of editing
+	synth        *audio.Synth    // Built-in synth notes play on without a MIDI output
+
+	// Pattern bank and song arrangement
+	bank          []pattern   // Stored patterns by slot; the edited one is stale until stored
+	slot          int         // Bank slot being edited
+	queued        int         // Slot to switch to at the next bar, if cued
+	cued          bool        // Whether a pattern switch is waiting for the bar to end
+	playingSlot   int         // Slot the scheduler last reported playing
+	song          []songEntry // Arrangement of patterns with repeat counts
+	songMode      bool        // Whether playback and export follow the song
+	songPos       int         // Bar of the song being played
+	selectingBank bool        // Whether the pattern bank overlay is open
+	bankCursor    int         // Highlighted slot in the bank overlay
+	editingSong   bool        // Whether the song overlay is open
+	songCursor    int         // Highlighted entry in the song overlay
+
+	histories map[string]*editHistory // Undo and redo stacks by file path, kept for the session
+
+	// Mixer
+	mix           [numChannels]channelMix   // Mute, solo and volume by channel
+	exportAudible bool                      // Whether saving leaves out channels that aren't heard
+	arps          [numChannels]arp.Settings // Arpeggiator by channel
+	arpEdit       *arpEditor                // Arpeggiator being set up, when the overlay is open
+	laneEdit      *laneEditor               // Lane view of the cursor's channel, when open
+
+	// Saving
+	dirty           bool          // Whether there are edits not yet saved
+	saveAs          *textPrompt   // File name prompt for save as, when open
+	confirmingLeave bool          // Whether we're asking what to do with unsaved changes
+	autosave        time.Duration // How often unsaved changes go to the recovery file (0 = off)
+	autosaveGen     int           // Bumped on every autosave setting change
+
+	// Chord and interval lists
+	picker       pickerKind // Which list overlay is open, if any
+	pickerCursor int        // Highlighted entry in the open list
+
+	// Visual selection and clipboard
+	selecting  bool        // Whether a rectangle from the anchor to the cursor is selected
+	anchorX    int         // Step where the selection started
+	anchorY    int         // Channel where the selection started
+	clipboard  clipboard   // Copied steps, kept across files for the session
+	fillPrompt *textPrompt // Step count prompt for filling the selection, when open
+
+	euclid *euclidRhythm // Euclidean rhythm being set up for the cursor's channel, when open
+
+	// Scale lock
+	key         scaleLock    // Root and scale that w/s step through
+	scaleEdit   *scaleEditor // Key being chosen, when the overlay is open
+	transposing *transposer  // Transposition being set up, when the overlay is open
+
+	// Probability steps
+	seed         uint64      // Seeds the dice, so a variation can be played and saved again
+	exportBars   int         // Bars of variation saving renders, zero for the pattern as written
+	exportPrompt *textPrompt // Bar count prompt, when open
+
+	generating *generatorEditor // Generator being set up, when the overlay is open
+	markov     *markovEditor    // Markov generator being set up, when the overlay is open
+
+}
+
+// overlayOpen reports whether a list or overlay has taken over the keys.
+func (s *sequencerModel) overlayOpen() bool {
+	return s.selectingPort || s.selectingSync || s.selectingInput || s.picker != pickerNone || s.selectingBank || s.editingSong ||
+		s.saveAs != nil || s.confirmingLeave || s.fillPrompt != nil ||
+		s.euclid != nil || s.scaleEdit != nil || s.transposing != nil ||
+		s.exportPrompt != nil || s.generating != nil || s.markov != nil ||
+		s.arpEdit != nil || s.laneEdit != nil
 }
 
 func (s *sequencerModel) refreshMIDIPorts() {
@@ -95,9 +229,27 @@ func (s *sequencerModel) selectPort(index int) error {
 		return fmt.Errorf("failed to open port %s: %w", out.String(), err)
 	}
 
+	// The scheduler sends from its own goroutine, so serialize access to the
+	// port and refuse sends once it has been closed
+	var mu sync.Mutex
+	closed := false
 	s.selectedOut = index
 	s.outPort = out
-	s.sendFunc = send
+	s.sendFunc = func(msg midi.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return fmt.Errorf("port %s is closed", out.String())
+		}
+		return send(msg)
+	}
+	s.closeOut = func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		closed = true
+		return out.Close()
+	}
+	s.sendVolumes()
 	s.message = fmt.Sprintf("Connected to: %s", out.String())
 	return nil
 }
@@ -111,74 +263,144 @@ func (s *sequencerModel) closePort() {
 				_ = s.sendFunc(midi.ControlChange(uint8(ch), 123, 0)) //nolint:gosec // All notes off
 			}
 		}
-		_ = s.outPort.Close()
+		_ = s.closeOut()
 		s.outPort = nil
 		s.sendFunc = nil
+		s.closeOut = nil
+		s.syncPlayer()
 	}
 }
 
 func (s *sequencerModel) sendNoteOn(channel, note, velocity uint8) {
-	if s.sendFunc != nil {
+	switch {
+	case s.sendFunc != nil:
 		_ = s.sendFunc(midi.NoteOn(channel, note, velocity))
+	case s.synth != nil:
+		s.synth.NoteOn(channel, note, velocity)
 	}
 }
 
 func (s *sequencerModel) sendNoteOff(channel, note uint8) {
-	if s.sendFunc != nil {
+	switch {
+	case s.sendFunc != nil:
 		_ = s.sendFunc(midi.NoteOff(channel, note))
+	case s.synth != nil:
+		s.synth.NoteOff(channel, note)
+	}
+}
+
+// velocity returns the step's velocity as a MIDI data byte, falling back to
+// the default for steps that were never given one.
+func (s *sequencerModel) velocity(ch, step int) uint8 {
+	v := s.velocities[ch][step]
+	if v < minVelocity || v > maxVelocity {
+		return defaultVelocity
 	}
+	return uint8(v) //nolint:gosec // v is bounded by minVelocity/maxVelocity
 }
 
 func (s *sequencerModel) sendAllNotesOff() {
-	if s.sendFunc != nil {
+	switch {
+	case s.sendFunc != nil:
 		for ch := 0; ch < numChannels; ch++ {
 			// Safe cast: ch is bounded by numChannels constant (4)
 			_ = s.sendFunc(midi.ControlChange(uint8(ch), 123, 0)) //nolint:gosec // All notes off
 		}
+	case s.synth != nil:
+		s.synth.AllNotesOff()
 	}
 }
 
+// stopPlayback stops playback and rewinds the playhead to the first step.
 func (s *sequencerModel) stopPlayback() {
+	s.haltPlayback()
+	s.isPaused = false
+	s.currentStep = 0
+	s.songPos = 0
+}
+
+// pausePlayback stops playback but keeps the playhead where it is, so
+// playback can continue from there.
+func (s *sequencerModel) pausePlayback() {
+	s.isPlaying = false
+	s.isPaused = true
+	s.haltPlayback()
+}
+
+// haltPlayback stops the scheduler and silences the output. A pattern switch
+// waiting for the bar to end is dropped.
+func (s *sequencerModel) haltPlayback() {
+	s.stopPlayer()
+	s.cued = false
 	if s.sendFunc != nil {
 		// Send note offs for any notes that were playing on the current step
 		for ch := 0; ch < numChannels; ch++ {
 			if s.steps[ch][s.currentStep] {
-				s.sendNoteOff(uint8(ch), uint8(s.notes[ch][s.currentStep])) //nolint:gosec
+				for _, note := range s.stepNotes(ch, s.currentStep) {
+					s.sendNoteOff(uint8(ch), note) //nolint:gosec // ch is bounded by numChannels (4)
+				}
 			}
 		}
 		// Send all notes off (CC#123) on all channels as a safety measure
 		s.sendAllNotesOff()
-		// Send MIDI Stop message (System Real-Time)
-		if err := s.sendFunc(midi.Stop()); err != nil {
-			s.message = fmt.Sprintf("Error sending MIDI stop: %v", err)
+		// Send MIDI Stop message (System Real-Time), unless an external master owns transport
+		if s.follower == nil {
+			if err := s.sendFunc(midi.Stop()); err != nil {
+				s.message = fmt.Sprintf("Error sending MIDI stop: %v", err)
+			}
 		}
+	} else {
+		// Playback on the built-in synth has only its notes to stop
+		s.sendAllNotesOff()
 	}
-	// Reset playback position
-	s.currentStep = 0
 }
 
 func (s *sequencerModel) createNewMIDI(path string) error {
 	s.filePath = path
+	// Edits to whatever was at this path before can't be undone into the new file
+	delete(s.histories, path)
+	s.discardRecovery(path)
+	s.dirty = false
+	s.saveAs = nil
+	s.confirmingLeave = false
+	s.selecting = false
+	s.fillPrompt = nil
+	s.euclid = nil
+	s.key = scaleLock{}
+	s.scaleEdit = nil
+	s.transposing = nil
+	s.seed = newSeed()
+	s.exportBars = 0
+	s.exportPrompt = nil
+	s.generating = nil
+	s.markov = nil
+	s.arps = [numChannels]arp.Settings{}
+	s.arpEdit = nil
+	s.laneEdit = nil
+	s.selectingInput = false
+	s.recording = recordOff
+	s.take = nil
+	s.playingPiano = false
 	s.bpm = 120
+	s.swing = minSwing
 	s.cursorX = 0
 	s.cursorY = 0
 	s.isPlaying = false
+	s.isPaused = false
 	s.currentStep = 0
+	s.looping = false
+	s.markingLoop = false
 	s.selectedOut = -1
 	s.selectingPort = false
+	s.picker = pickerNone
 	s.message = "New MIDI file created"
 
 	// Refresh available MIDI ports
 	s.refreshMIDIPorts()
 
-	// Initialize with default notes (C4, D4, E4, F4) for each step
-	defaultNotes := [numChannels]int{60, 62, 64, 65}
-	for i := 0; i < numChannels; i++ {
-		for j := 0; j < numSteps; j++ {
-			s.notes[i][j] = defaultNotes[i] //nolint:gosec // i is bounded by numChannels constant
-			s.steps[i][j] = false
-		}
-	}
+	// Start with an empty bank of default patterns and a flat mix
+	s.resetBank()
+	s.resetMix()
 
 	return s.saveMIDI()
 }
@@ -186,86 +408,185 @@ func (s *sequencerModel) createNewMIDI(path string) error {
 func (s *sequencerModel) loadMIDI(path string) error {
 	s.filePath = path
 	s.bpm = 120
+	s.swing = minSwing
 	s.cursorX = 0
 	s.cursorY = 0
 	s.isPlaying = false
+	s.isPaused = false
 	s.currentStep = 0
+	s.looping = false
+	s.markingLoop = false
 	s.selectedOut = -1
 	s.selectingPort = false
+	s.picker = pickerNone
 	s.message = fmt.Sprintf("Loaded: %s", path)
 
 	// Refresh available MIDI ports
 	s.refreshMIDIPorts()
 
-	// Initialize with default notes
-	defaultNotes := [numChannels]int{60, 62, 64, 65}
-	for i := 0; i < numChannels; i++ {
-		for j := 0; j < numSteps; j++ {
-			s.notes[i][j] = defaultNotes[i] //nolint:gosec // i is bounded by numChannels constant
-			s.steps[i][j] = false
-		}
+	// Start with an empty bank of default patterns and a flat mix
+	s.resetBank()
+	s.resetMix()
+
+	s.dirty = false
+	s.saveAs = nil
+	s.confirmingLeave = false
+	s.selecting = false
+	s.fillPrompt = nil
+	s.euclid = nil
+	s.key = scaleLock{}
+	s.scaleEdit = nil
+	s.transposing = nil
+	s.seed = newSeed()
+	s.exportBars = 0
+	s.exportPrompt = nil
+	s.generating = nil
+	s.markov = nil
+	s.arps = [numChannels]arp.Settings{}
+	s.arpEdit = nil
+	s.laneEdit = nil
+	s.selectingInput = false
+	s.recording = recordOff
+	s.take = nil
+	s.playingPiano = false
+
+	// Unsaved changes autosaved before the last session ended win over the file
+	source := path
+	if _, err := os.Stat(recoveryPath(path)); err == nil {
+		source = recoveryPath(path)
 	}
 
 	// Try to parse existing MIDI file
-	rd, err := smf.ReadFile(path)
+	rd, err := smf.ReadFile(source)
 	if err != nil {
 		// If file doesn't exist, create a new one
 		return s.saveMIDI()
 	}
+	if source != path {
+		s.dirty = true
+		s.message = "Restored unsaved changes from autosave: ctrl+s keeps them, leaving without saving discards them"
+	}
+
+	s.importSMF(rd)
+	return nil
+}
 
+// importSMF loads the tempo, settings and notes of a parsed file into the
+// freshly reset bank. Files saved by the sequencer restore their bank from
+// the settings; other files load bar by bar into consecutive slots.
+func (s *sequencerModel) importSMF(rd *smf.SMF) {
 	// Extract tempo if available
 	tempoChanges := rd.TempoChanges()
 	if len(tempoChanges) > 0 {
-		s.bpm = int(tempoChanges[0].BPM)
+		// Playback divides by the tempo, so keep it in the range it can be set to
+		s.bpm = min(max(int(math.Round(tempoChanges[0].BPM)), minBPM), maxBPM)
 	}
 
 	// Parse tracks to extract note data
-	// Calculate ticks per step (one bar = 4 beats = 16 steps)
-	ticksPerStep := uint32(ticksPerQuarterNote / 4) // 240 ticks per step
-
 	tracks := rd.Tracks
+	if len(tracks) > 0 {
+		// Swing has to be known before notes can be placed on steps
+		if s.readMeta(tracks[0]) {
+			// The bank was saved whole; the note tracks are only its
+			// rendering, unless they have been edited since
+			if s.matchesBank(tracks) {
+				return
+			}
+			s.resetBank()
+			s.arps = [numChannels]arp.Settings{}
+			s.message = "The notes were edited outside genidi, so they were loaded in place of the saved pattern bank"
+		}
+	}
 	// Skip track 0 (tempo track), process remaining tracks as channels
+	bars := 1
 	for trackIdx := 1; trackIdx < len(tracks) && trackIdx <= numChannels; trackIdx++ {
 		ch := trackIdx - 1 // Track 1 maps to channel 0, etc.
 		track := tracks[trackIdx]
 
-		// Parse messages in the track
+		// Parse messages in the track, pairing each note on with its note off
+		// so the note's length can become a gate or a tie
+		var imported []importedNote
+		var controls []importedControl
+		held := make(map[uint8]int) // key -> index into imported
 		var currentTick uint32
 		for _, msg := range track {
 			currentTick += msg.Delta
 
-			// Check if this is a note on message
-			var channel, key, velocity uint8
-			if msg.Message.GetNoteOn(&channel, &key, &velocity) {
-				// Calculate which step this note belongs to
-				step := int(currentTick / ticksPerStep)
-				if step < numSteps && velocity > 0 {
-					s.notes[ch][step] = int(key)
-					s.steps[ch][step] = true
+			var channel, key, velocity, controller, value uint8
+			switch {
+			case msg.Message.GetNoteStart(&channel, &key, &velocity):
+				if i, ok := held[key]; ok {
+					// Retriggered before its note off; end the earlier note here
+					imported[i].length = currentTick - imported[i].start
+				}
+				held[key] = len(imported)
+				imported = append(imported, importedNote{start: currentTick, key: key, velocity: velocity})
+			case msg.Message.GetNoteEnd(&channel, &key):
+				if i, ok := held[key]; ok {
+					imported[i].length = currentTick - imported[i].start
+					delete(held, key)
 				}
+			case msg.Message.GetControlChange(&channel, &controller, &value) && controller == ccVolume:
+				s.mix[ch].volume = int(value)
+			case msg.Message.GetControlChange(&channel, &controller, &value):
+				controls = append(controls, importedControl{tick: currentTick, controller: controller, value: value})
+			}
+		}
+
+		// Notes are collected in start order, so simultaneous notes become chords
+		// and later notes cut off earlier ties. Unreleased notes play one step.
+		// Each bar of a longer file loads into the next bank slot.
+		for _, n := range imported {
+			bar := int(n.start / patternTicks)
+			if bar >= numPatterns {
+				break
 			}
+			s.selectPattern(bar)
+			s.placeNote(ch, n.start%patternTicks, n.length, n.key, n.velocity)
+			bars = max(bars, bar+1)
 		}
+		// Other controllers become CC lanes
+		bars = max(bars, s.placeControls(ch, controls))
+	}
+	s.selectPattern(0)
+
+	// Play a multi-bar file back as it was written
+	if bars > 1 {
+		for bar := 0; bar < bars; bar++ {
+			s.song = append(s.song, songEntry{slot: bar, repeats: 1})
+		}
+		s.songMode = true
 	}
 
-	return nil
 }
 
 func (s *sequencerModel) saveMIDI() error {
 	if s.filePath == "" {
 		return fmt.Errorf("no file path set")
 	}
+	if err := s.writeMIDI(s.filePath); err != nil {
+		return err
+	}
 
+	s.message = "MIDI file saved"
+	return nil
+}
+
+// writeMIDI writes the project to path as a Standard MIDI File.
+func (s *sequencerModel) writeMIDI(path string) error {
 	// Create a new SMF file
 	sm := smf.New()
 	sm.TimeFormat = smf.MetricTicks(ticksPerQuarterNote)
 
-	// Calculate ticks per step (one bar = 4 beats = 16 steps)
-	ticksPerStep := uint32(ticksPerQuarterNote / 4) // 240 ticks per step
-
 	// Track 0: Tempo track
 	var track0 smf.Track
 	track0.Add(0, smf.MetaMeter(4, 4))
 	track0.Add(0, smf.MetaTempo(float64(s.bpm)))
+	meta, err := s.metaMessage()
+	if err != nil {
+		return err
+	}
+	track0.Add(0, meta)
 	track0.Close(0)
 	if err := sm.Add(track0); err != nil {
 		return fmt.Errorf("error adding tempo track: %w", err)
@@ -273,46 +594,88 @@ func (s *sequencerModel) saveMIDI() error {
 
 	// Create tracks for each channel
 	for ch := 0; ch < numChannels; ch++ {
-		var track smf.Track
-		var lastTick uint32 = 0
-
-		for step := 0; step < numSteps; step++ {
-			if s.steps[ch][step] {
-				pos := uint32(step) * ticksPerStep //nolint:gosec // step is bounded by numSteps constant
-				delta := pos - lastTick
-				// Note on
-				track.Add(delta, midi.NoteOn(uint8(ch), uint8(s.notes[ch][step]), 100)) //nolint:gosec // ch is bounded by numChannels constant
-				lastTick = pos
-				// Note off after one step
-				track.Add(ticksPerStep-1, midi.NoteOff(uint8(ch), uint8(s.notes[ch][step]))) //nolint:gosec // ch is bounded by numChannels constant
-				lastTick += ticksPerStep - 1
-			}
-		}
-		// Close track - ensure we don't have negative delta
-		endTick := uint32(numSteps) * ticksPerStep
-		if lastTick < endTick {
-			track.Close(endTick - lastTick)
-		} else {
-			track.Close(0)
-		}
-		if err := sm.Add(track); err != nil {
+		if err := sm.Add(s.noteTrack(ch)); err != nil {
 			return fmt.Errorf("error adding track %d: %w", ch, err)
 		}
 	}
 
 	// Write to file
-	err := sm.WriteFile(s.filePath)
-	if err != nil {
+	if err := sm.WriteFile(path); err != nil {
 		return fmt.Errorf("error writing MIDI file: %w", err)
 	}
-
-	s.message = "MIDI file saved"
 	return nil
 }
 
+// noteTrack renders a channel's track as it is saved, following the song in
+// song mode, or playing out a number of bars of variation.
+func (s *sequencerModel) noteTrack(ch int) smf.Track {
+	order := s.timeline()
+	bars := len(order)
+	if s.exportBars > 0 {
+		bars = s.exportBars
+	}
+
+	var track smf.Track
+	var lastTick uint32
+
+	// Channel volume first, then the notes unless the channel is left out
+	track.Add(0, s.volumeMessage(ch))
+	events := s.timelineEvents(order, ch)
+	if s.exportBars > 0 {
+		events = s.variationEvents(order, bars, ch)
+	}
+	if s.exportAudible && !s.audible(ch) {
+		events = nil
+	}
+	for _, ev := range events {
+		track.Add(ev.tick-lastTick, ev.message())
+		lastTick = ev.tick
+	}
+	// Close track - ensure we don't have negative delta
+	endTick := uint32(bars) * patternTicks //nolint:gosec // bounded by the song's repeat counts or maxExportBars
+	if lastTick < endTick {
+		track.Close(endTick - lastTick)
+	} else {
+		track.Close(0)
+	}
+	return track
+}
+
 func (m model) updateSequencer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	h, before := m.sequencer.history(), m.sequencer.snapshot(m.sequencer.slot)
+
+	next, cmd := m.updateSequencerKey(msg)
+	m = next.(model)
+	s := &m.sequencer
+
+	// Any change leaves the file unsaved and, unless it came from undo or
+	// redo, can be undone
+	if s.changedSince(before) {
+		s.dirty = true
+		if key := msg.String(); key != "u" && key != "ctrl+r" {
+			h.push(before)
+		}
+	}
+	// Pass any edit on to the scheduler if the pattern is playing
+	s.syncPlayer()
+	return m, cmd
+}
+
+func (m model) updateSequencerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	s := &m.sequencer
 
+	// Handle the save-as prompt and the unsaved changes question
+	if s.saveAs != nil {
+		s.updateSaveAs(msg)
+		return m, nil
+	}
+	if s.confirmingLeave {
+		if s.updateConfirmLeave(msg) {
+			m = m.leaveSequencer()
+		}
+		return m, nil
+	}
+
 	// Handle port selection mode
 	if s.selectingPort {
 		switch msg.String() {
@@ -343,6 +706,75 @@ func (m model) updateSequencer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	// Handle clock source selection
+	if s.selectingSync {
+		return m, s.updateSyncSelection(msg)
+	}
+
+	// Handle note input selection
+	if s.selectingInput {
+		return m, s.updateInputSelection(msg)
+	}
+
+	// Handle chord and interval lists
+	if s.picker != pickerNone {
+		s.updatePicker(msg)
+		return m, nil
+	}
+
+	// Handle the pattern bank and song overlays
+	if s.selectingBank {
+		s.updateBank(msg)
+		return m, nil
+	}
+	if s.editingSong {
+		s.updateSong(msg)
+		return m, nil
+	}
+
+	// Handle the Euclidean rhythm generator and the scale lock
+	if s.euclid != nil {
+		s.updateEuclid(msg)
+		return m, nil
+	}
+	if s.scaleEdit != nil {
+		s.updateScale(msg)
+		return m, nil
+	}
+	if s.transposing != nil {
+		s.updateTranspose(msg)
+		return m, nil
+	}
+	if s.exportPrompt != nil {
+		s.updateExportBars(msg)
+		return m, nil
+	}
+	if s.generating != nil {
+		s.updateGenerator(msg)
+		return m, nil
+	}
+	if s.markov != nil {
+		s.updateMarkov(msg)
+		return m, nil
+	}
+	if s.arpEdit != nil {
+		s.updateArp(msg)
+		return m, nil
+	}
+	if s.laneEdit != nil {
+		s.updateLanes(msg)
+		return m, nil
+	}
+
+	// Handle the visual selection
+	if s.fillPrompt != nil {
+		s.updateFill(msg)
+		return m, nil
+	}
+	if s.selecting && s.updateSelection(msg) {
+		return m, nil
+	}
+
 	switch msg.String() {
 	case keyLeft, "h":
 		if s.cursorX > 0 {
@@ -363,65 +795,214 @@ func (m model) updateSequencer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case " ":
 		// Toggle step
 		s.steps[s.cursorY][s.cursorX] = !s.steps[s.cursorY][s.cursorX]
-		if err := s.saveMIDI(); err != nil {
-			s.message = fmt.Sprintf("Error saving: %v", err)
-		}
 	case "+", "=":
 		// Increase BPM
-		if s.bpm < 300 {
+		if s.bpm < maxBPM {
 			s.bpm += 5
-			if err := s.saveMIDI(); err != nil {
-				s.message = fmt.Sprintf("Error saving: %v", err)
-			}
 		}
 	case "-", "_":
 		// Decrease BPM
-		if s.bpm > 20 {
+		if s.bpm > minBPM {
 			s.bpm -= 5
-			if err := s.saveMIDI(); err != nil {
-				s.message = fmt.Sprintf("Error saving: %v", err)
-			}
 		}
 	case "w":
-		// Increase note for current step
-		if s.notes[s.cursorY][s.cursorX] < 127 {
-			s.notes[s.cursorY][s.cursorX]++
-			if err := s.saveMIDI(); err != nil {
-				s.message = fmt.Sprintf("Error saving: %v", err)
-			}
-		}
+		// Increase note for current step, staying in the scale
+		s.stepNoteInScale(1)
 	case "s":
-		// Decrease note for current step
-		if s.notes[s.cursorY][s.cursorX] > 0 {
-			s.notes[s.cursorY][s.cursorX]--
-			if err := s.saveMIDI(); err != nil {
-				s.message = fmt.Sprintf("Error saving: %v", err)
+		// Decrease note for current step, staying in the scale
+		s.stepNoteInScale(-1)
+	case "e":
+		// Increase velocity for current step
+		if v := s.velocities[s.cursorY][s.cursorX]; v < maxVelocity {
+			s.velocities[s.cursorY][s.cursorX] = min(v+velocityIncrement, maxVelocity)
+		}
+	case "d":
+		// Decrease velocity for current step
+		if v := s.velocities[s.cursorY][s.cursorX]; v > minVelocity {
+			s.velocities[s.cursorY][s.cursorX] = max(v-velocityIncrement, minVelocity)
+		}
+	case "]":
+		// Lengthen gate for current step
+		if g := s.gate(s.cursorY, s.cursorX); g < maxGate {
+			s.gates[s.cursorY][s.cursorX] = min(g+gateIncrement, maxGate)
+		}
+	case "[":
+		// Shorten gate for current step
+		if g := s.gate(s.cursorY, s.cursorX); g > minGate {
+			s.gates[s.cursorY][s.cursorX] = max(g-gateIncrement, minGate)
+		}
+	case "}":
+		// Increase swing
+		if s.swing < maxSwing {
+			s.swing = min(s.swing+swingIncrement, maxSwing)
+		}
+	case "{":
+		// Decrease swing
+		if s.swing > minSwing {
+			s.swing = max(s.swing-swingIncrement, minSwing)
+		}
+	case ">":
+		// Nudge current step later
+		if n := s.nudges[s.cursorY][s.cursorX]; n < maxNudge {
+			s.nudges[s.cursorY][s.cursorX] = min(n+nudgeIncrement, maxNudge)
+		}
+	case "<":
+		// Nudge current step earlier
+		if n := s.nudges[s.cursorY][s.cursorX]; n > -maxNudge {
+			s.nudges[s.cursorY][s.cursorX] = max(n-nudgeIncrement, -maxNudge)
+		}
+	case "t":
+		// Tie current step into the next one, enabling it with the same note
+		if s.cursorX == numSteps-1 {
+			s.message = "Cannot tie past the last step"
+			break
+		}
+		s.ties[s.cursorY][s.cursorX] = !s.ties[s.cursorY][s.cursorX]
+		if s.ties[s.cursorY][s.cursorX] {
+			s.steps[s.cursorY][s.cursorX] = true
+			s.steps[s.cursorY][s.cursorX+1] = true
+			s.notes[s.cursorY][s.cursorX+1] = s.notes[s.cursorY][s.cursorX]
+			s.chords[s.cursorY][s.cursorX+1] = slices.Clone(s.chords[s.cursorY][s.cursorX])
+			s.velocities[s.cursorY][s.cursorX+1] = s.velocities[s.cursorY][s.cursorX]
+		}
+	case "C":
+		// Pick a chord type for current step
+		s.openPicker(pickerChord)
+	case "a":
+		// Add an interval above the root of current step
+		s.openPicker(pickerInterval)
+	case "x":
+		// Remove chord notes from current step, leaving just the root
+		last := s.chainEnd(s.cursorY, s.cursorX)
+		for i := s.cursorX; i <= last; i++ {
+			s.chords[s.cursorY][i] = nil
+		}
+	case "i":
+		// Open clock source selection
+		s.refreshMIDIIns()
+		s.selectingSync = true
+		s.syncCursor = 0
+		for i, name := range s.midiInNames {
+			if s.follower != nil && s.follower.in.String() == name {
+				s.syncCursor = i + 1
 			}
 		}
-	case "p":
-		// Toggle playback
-		s.isPlaying = !s.isPlaying
-		if s.isPlaying {
-			s.currentStep = 0
-			// Play notes at step 0 immediately
-			for ch := 0; ch < numChannels; ch++ {
-				if s.steps[ch][0] {
-					s.sendNoteOn(uint8(ch), uint8(s.notes[ch][0]), 100) //nolint:gosec
-				}
+	case "I":
+		// Open note input selection
+		s.refreshMIDIIns()
+		s.selectingInput = true
+		s.inputCursor = 0
+		for i, name := range s.midiInNames {
+			if s.noteIn != nil && s.noteIn.in.String() == name {
+				s.inputCursor = i + 1
 			}
-			return m, tickWithBPM(s.bpm)
-		} else {
-			// Stop playback - send note offs for currently playing step and reset state
-			s.stopPlayback()
 		}
+	case "W":
+		// Arm the current channel for recording
+		s.cycleRecord()
+	case "tab":
+		// Play the computer keyboard as a piano
+		s.openPiano()
+	case "p", "P", "S":
+		if s.follower != nil {
+			s.message = "Following external clock: start and stop from the master"
+			break
+		}
+		return m, s.transport(msg.String())
+	case "L":
+		s.markLoop()
+	case "u":
+		s.undo()
+	case "ctrl+r":
+		s.redo()
+	case "ctrl+s":
+		if err := s.save(); err != nil {
+			s.message = fmt.Sprintf("Error saving: %v", err)
+		}
+	case "ctrl+o":
+		s.openSaveAs()
+	case "A":
+		return m, s.cycleAutosave()
+	case "(":
+		s.cueNeighbour(-1)
+	case ")":
+		s.cueNeighbour(1)
+	case "b":
+		// Open the pattern bank
+		s.selectingBank = true
+		s.bankCursor = s.slot
+	case "g":
+		// Open the song arrangement
+		s.editingSong = true
+		s.songCursor = max(min(s.songCursor, len(s.song)-1), 0)
+	case "v":
+		// Start a visual selection at the cursor
+		s.selecting = true
+		s.anchorX, s.anchorY = s.cursorX, s.cursorY
+		s.message = "Selecting: move to extend • y: copy • x: cut • ctrl+v: paste • D: duplicate right • f: fill every N • esc: cancel"
+	case "y":
+		s.copySelection()
+	case "ctrl+v":
+		s.paste()
+	case "D":
+		s.duplicateRight()
+	case "0":
+		// Make current step more likely to play
+		s.changeChance(chanceIncrement)
+	case "9":
+		// Make current step less likely to play
+		s.changeChance(-chanceIncrement)
+	case "n":
+		// Next pass condition for current step
+		s.cycleCondition(1)
+	case "N":
+		// Previous pass condition for current step
+		s.cycleCondition(-1)
+	case "r":
+		// Split current step into more repeats
+		s.cycleRatchet()
+	case "Z":
+		s.rerollSeed()
+	case "X":
+		s.openExportBars()
+	case "G":
+		s.openGenerator()
+	case "H":
+		// Generate in the style of the files in this directory
+		s.openMarkov()
+	case "U":
+		// Arpeggiate the current channel
+		s.openArp()
+	case "V":
+		// Automate controllers on the current channel
+		s.openLanes()
+	case "K":
+		// Choose the scale lock
+		s.openScale()
+	case "Q":
+		// Move notes onto the scale
+		s.quantizeNotes()
+	case "T":
+		// Transpose the selection, channel or pattern
+		s.openTranspose()
+	case "R":
+		// Fill the current channel with a Euclidean rhythm
+		s.openEuclid()
+	case "m":
+		s.toggleMix(false)
+	case "M":
+		s.toggleMix(true)
+	case ".":
+		s.changeVolume(volumeIncrement)
+	case ",":
+		s.changeVolume(-volumeIncrement)
+	case "E":
+		s.toggleExportAudible()
 	case "c":
 		// Clear all steps in current channel
 		for i := 0; i < numSteps; i++ {
 			s.steps[s.cursorY][i] = false
 		}
-		if err := s.saveMIDI(); err != nil {
-			s.message = fmt.Sprintf("Error saving: %v", err)
-		}
 	case "o":
 		// Open MIDI output port selection
 		s.refreshMIDIPorts()
@@ -436,31 +1017,80 @@ func (m model) updateSequencer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func tickWithBPM(bpm int) tea.Cmd {
-	// Calculate step interval based on BPM
-	// BPM = beats per minute, 16 steps = 4 beats (16th notes)
-	// So each step = (60000ms / BPM) / 4
-	stepIntervalMs := 60000 / bpm / 4
-	return tea.Tick(time.Millisecond*time.Duration(stepIntervalMs), func(t time.Time) tea.Msg {
-		return tickMsg(t)
-	})
-}
-
 func (m model) viewSequencer() string {
 	s := m.sequencer
 
 	var b strings.Builder
 
 	// Title
-	b.WriteString(titleStyle.Render("MIDI Sequencer Editor") + "\n\n")
-	fmt.Fprintf(&b, "File: %s\n", s.filePath)
-	fmt.Fprintf(&b, "BPM: %d (use +/- to adjust)\n", s.bpm)
+	b.WriteString(titleStyle.Render("MIDI Sequencer Editor"))
+	if s.dirty {
+		b.WriteString(" " + errorStyle.Render("● unsaved"))
+	}
+	if s.follower != nil {
+		b.WriteString(" " + syncStyle.Render("EXT SYNC"))
+	}
+	if s.recording != recordOff {
+		b.WriteString(" " + recordStyle.Render("● REC"))
+	}
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "File: %s • Autosave: %s\n", s.filePath, autosaveLabel(s.autosave))
+	if s.follower != nil {
+		fmt.Fprintf(&b, "BPM: %s (external clock: %s) • Swing: %d%% • Scale: %s\n", externalTempo(s.follower.tempo()), s.follower.in.String(), max(s.swing, minSwing), s.key.name())
+	} else {
+		fmt.Fprintf(&b, "BPM: %d (use +/- to adjust) • Swing: %d%% • Scale: %s\n", s.bpm, max(s.swing, minSwing), s.key.name())
+	}
+	transport := "■ Stopped"
+	if s.isPlaying {
+		transport = "▶ Playing"
+	} else if s.isPaused {
+		transport = fmt.Sprintf("⏸ Paused at step %X", s.currentStep)
+	}
+	loop := "off"
+	if s.looping {
+		loop = fmt.Sprintf("steps %X-%X", s.loopStart, s.loopEnd)
+	} else if s.markingLoop {
+		loop = fmt.Sprintf("from step %X, press L on the last step", s.loopStart)
+	}
+	fmt.Fprintf(&b, "Transport: %s • Loop: %s\n", transport, loop)
+	next := ""
+	if s.cued {
+		next = fmt.Sprintf(" (next: %s)", slotName(s.queued))
+	}
+	song := "off"
+	if s.songMode {
+		song = fmt.Sprintf("bar %d of %d", s.songPos+1, len(s.timeline()))
+	}
+	fmt.Fprintf(&b, "Pattern: %s%s • Song: %s\n", slotName(s.slot), next, song)
+	tie := ""
+	if s.tiedToNext(s.cursorY, s.cursorX) {
+		tie = " tied"
+	}
+	fmt.Fprintf(&b, "Step %X: %s vel %d gate %d%% nudge %+d%%%s%s%s\n", s.cursorX, s.chordName(s.cursorY, s.cursorX),
+		s.velocity(s.cursorY, s.cursorX), s.gate(s.cursorY, s.cursorX), s.nudges[s.cursorY][s.cursorX], tie,
+		s.trigLabel(s.cursorY, s.cursorX), s.lockLabel(s.cursorY, s.cursorX))
+	if s.arp(s.cursorY).Mode != arp.Off {
+		b.WriteString(s.arpLabel() + "\n")
+	}
 
 	// MIDI output status
 	if s.outPort != nil {
-		fmt.Fprintf(&b, "MIDI Out: %s ✓\n\n", s.outPort.String())
+		fmt.Fprintf(&b, "MIDI Out: %s ✓ (clock master)\n", s.outPort.String())
 	} else {
-		b.WriteString("MIDI Out: Not connected (press 'o' to select)\n\n")
+		b.WriteString("MIDI Out: Not connected (press 'o' to select)\n")
+	}
+	b.WriteString(s.recordLabel() + "\n")
+	if s.playingPiano {
+		b.WriteString(s.pianoLabel() + "\n")
+	}
+	b.WriteString("\n")
+
+	// Save prompts
+	if s.saveAs != nil {
+		return s.saveAs.view()
+	}
+	if s.confirmingLeave {
+		return m.viewConfirmLeave()
 	}
 
 	// Port selection overlay
@@ -468,16 +1098,79 @@ func (m model) viewSequencer() string {
 		return m.viewPortSelection()
 	}
 
+	// Clock source selection overlay
+	if s.selectingSync {
+		return m.viewSyncSelection()
+	}
+
+	// Note input selection overlay
+	if s.selectingInput {
+		return m.viewInputSelection()
+	}
+
+	// Chord and interval lists
+	if s.picker != pickerNone {
+		return m.viewPicker()
+	}
+
+	// Pattern bank and song overlays
+	if s.selectingBank {
+		return m.viewBank()
+	}
+	if s.editingSong {
+		return m.viewSong()
+	}
+
+	// Fill prompt for the selection
+	if s.fillPrompt != nil {
+		return s.fillPrompt.view()
+	}
+
+	// Euclidean rhythm generator and scale lock
+	if s.euclid != nil {
+		return m.viewEuclid()
+	}
+	if s.scaleEdit != nil {
+		return m.viewScale()
+	}
+	if s.transposing != nil {
+		return m.viewTranspose()
+	}
+	if s.exportPrompt != nil {
+		return m.viewExportBars()
+	}
+	if s.generating != nil {
+		return m.viewGenerator()
+	}
+	if s.markov != nil {
+		return m.viewMarkov()
+	}
+	if s.arpEdit != nil {
+		return m.viewArp()
+	}
+	if s.laneEdit != nil {
+		return m.viewLanes()
+	}
 
 	// Header row with proper spacing
-	// 14 chars to match data rows: 8 for channel + 6 for note
-	b.WriteString("Chan    Note  ")
+	// 18 chars to match data rows: 12 for channel and mix + 6 for note
+	b.WriteString("Chan MS Vol Note  ")
 	hexDigits := "0123456789ABCDEF"
+	playheadColor := lipgloss.Color("#7D56F4")
+	if s.follower != nil {
+		playheadColor = syncColor
+	}
+	// A paused playhead stays visible where playback will continue
+	showPlayhead := s.isPlaying || s.isPaused
 	for i := 0; i < numSteps; i++ {
 		headerStyle := lipgloss.NewStyle().Width(3).Align(lipgloss.Center).Foreground(lipgloss.Color("#888888"))
+		// Mark the looped range
+		if s.looping && i >= s.loopStart && i <= s.loopEnd {
+			headerStyle = headerStyle.Foreground(loopColor).Underline(true)
+		}
 		// Highlight the currently playing column
-		if s.isPlaying && i == s.currentStep {
-			headerStyle = headerStyle.Background(lipgloss.Color("#7D56F4")).Foreground(lipgloss.Color("#FFFFFF")).Bold(true)
+		if showPlayhead && i == s.currentStep {
+			headerStyle = headerStyle.Background(playheadColor).Foreground(lipgloss.Color("#FFFFFF")).Bold(true)
 		}
 		b.WriteString(headerStyle.Render(string(hexDigits[i])))
 	}
@@ -485,18 +1178,25 @@ func (m model) viewSequencer() string {
 
 	// Sequencer grid
 	for ch := 0; ch < numChannels; ch++ {
-		// Channel indicator (8 chars wide to match "Channel  ")
-		if ch == s.cursorY {
-			b.WriteString(selectedStyle.Render(fmt.Sprintf("Ch %-5d", ch+1)))
-		} else {
-			b.WriteString(fmt.Sprintf("Ch %-5d", ch+1))
+		// Channel indicator with mute, solo and volume (12 chars wide)
+		label := fmt.Sprintf("Ch %d %s ", ch+1, s.mixLabel(ch))
+		switch {
+		case ch == s.cursorY:
+			b.WriteString(selectedStyle.Render(label))
+		case !s.audible(ch):
+			b.WriteString(helpStyle.Render(label))
+		default:
+			b.WriteString(label)
 		}
 
 		// Note display for current cursor position (5 chars wide to match "Note  ")
 		noteName := midiNoteToName(s.notes[ch][s.cursorX])
-		if ch == s.cursorY {
+		switch {
+		case !s.key.inScale(s.notes[ch][s.cursorX]):
+			b.WriteString(lipgloss.NewStyle().Foreground(outOfScaleColor).Render(fmt.Sprintf("%-5s ", noteName)))
+		case ch == s.cursorY:
 			b.WriteString(selectedStyle.Render(fmt.Sprintf("%-5s ", noteName)))
-		} else {
+		default:
 			b.WriteString(fmt.Sprintf("%-5s ", noteName))
 		}
 
@@ -504,7 +1204,15 @@ func (m model) viewSequencer() string {
 		for step := 0; step < numSteps; step++ {
 			// Determine cell content
 			var cell string
-			if s.steps[ch][step] {
+			if s.isTieContinuation(ch, step) {
+				cell = "━"
+			} else if s.steps[ch][step] && s.ratchet(ch, step) > 1 {
+				cell = "≡"
+			} else if s.steps[ch][step] && !s.trigRule(ch, step).always() {
+				cell = "◐"
+			} else if s.steps[ch][step] && len(s.chords[ch][step]) > 0 {
+				cell = "◆"
+			} else if s.steps[ch][step] {
 				cell = "●"
 			} else {
 				cell = "·"
@@ -514,8 +1222,13 @@ func (m model) viewSequencer() string {
 			cellStyle := lipgloss.NewStyle().Width(3).Align(lipgloss.Center)
 
 			// Highlight the currently playing column
-			if s.isPlaying && step == s.currentStep {
-				cellStyle = cellStyle.Background(lipgloss.Color("#7D56F4"))
+			if showPlayhead && step == s.currentStep {
+				cellStyle = cellStyle.Background(playheadColor)
+			}
+
+			// Highlight the visual selection
+			if s.inSelection(ch, step) {
+				cellStyle = cellStyle.Background(selectionColor)
 			}
 
 			// Highlight current cursor position (overrides playing column)
@@ -523,9 +1236,11 @@ func (m model) viewSequencer() string {
 				cellStyle = cellStyle.Background(lipgloss.Color("#5A3DBF"))
 			}
 
-			// Active step gets color
-			if s.steps[ch][step] {
-				cellStyle = cellStyle.Foreground(lipgloss.Color("#FFD700"))
+			// Active step gets color, shaded by velocity, unless it is out of the scale
+			if s.steps[ch][step] && !s.stepInScale(ch, step) {
+				cellStyle = cellStyle.Foreground(outOfScaleColor)
+			} else if s.steps[ch][step] {
+				cellStyle = cellStyle.Foreground(velocityColor(s.velocity(ch, step)))
 			} else {
 				cellStyle = cellStyle.Foreground(lipgloss.Color("#666666"))
 			}
@@ -540,8 +1255,20 @@ func (m model) viewSequencer() string {
 		b.WriteString(errorStyle.Render(s.message) + "\n")
 	}
 
-	b.WriteString("\n" + helpStyle.Render("Navigation: ↑↓←→ or hjkl • Space: toggle step • w/s: change note (for current step)"))
-	b.WriteString("\n" + helpStyle.Render("+/-: tempo • p: play/stop • c: clear channel • o: MIDI output • q: back to files"))
+	b.WriteString("\n" + helpStyle.Render("Navigation: ↑↓←→ or hjkl • Space: toggle step • w/s: change note • e/d: change velocity (for current step)"))
+	b.WriteString("\n" + helpStyle.Render("[/]: gate length • t: tie to next step • C: chord • a: add interval • x: clear chord"))
+	b.WriteString("\n" + helpStyle.Render("{/}: swing • </>: nudge step earlier/later • R: Euclidean rhythm • G: generate • H: generate like this folder's files"))
+	b.WriteString("\n" + helpStyle.Render("U: arpeggiator for the current channel (mode, rate, octaves, gate) • V: CC lanes and parameter locks"))
+	b.WriteString("\n" + helpStyle.Render("9/0: step chance • n/N: play on pass n of every N • r: ratchet • Z: new variation • X: save N bars of variation"))
+	b.WriteString("\n" + helpStyle.Render("v: select • y: copy • ctrl+v: paste • D: duplicate right (in a selection also x: cut • f: fill every N)"))
+	b.WriteString("\n" + helpStyle.Render("p: play/pause/continue • P: play from cursor • S: stop • L: mark loop start/end (again: loop off)"))
+	b.WriteString("\n" + helpStyle.Render("(/): previous/next pattern • b: pattern bank • g: song arrangement"))
+	b.WriteString("\n" + helpStyle.Render("ctrl+s: save • ctrl+o: save as • A: autosave interval • u: undo • ctrl+r: redo"))
+	b.WriteString("\n" + helpStyle.Render("K: scale lock (w/s then move by scale degree) • Q: quantize notes to the scale • T: transpose"))
+	b.WriteString("\n" + helpStyle.Render("m: mute • M: solo • ,/.: channel volume • E: save only audible channels"))
+	b.WriteString("\n" + helpStyle.Render("I: MIDI input for notes (step entry when stopped) • W: arm channel to record (overdub, replace, off)"))
+	b.WriteString("\n" + helpStyle.Render("tab: keyboard as piano (a-' and w-p: notes • z/x: octave • c/v: velocity • enter: audition/enter notes • space: play • ←→↑↓: move • tab/esc: back)"))
+	b.WriteString("\n" + helpStyle.Render("+/-: tempo • c: clear channel • o: MIDI output • i: clock source • q: back to files"))
 
 	return b.String()
 }
@@ -587,9 +1314,22 @@ func (m model) viewPortSelection() string {
 	return b.String()
 }
 
+// velocityColor shades active steps from dim amber (soft) to bright gold (loud).
+func velocityColor(velocity uint8) lipgloss.Color {
+	switch {
+	case velocity >= 112:
+		return lipgloss.Color("#FFD700")
+	case velocity >= 80:
+		return lipgloss.Color("#D4AF00")
+	case velocity >= 48:
+		return lipgloss.Color("#A08400")
+	default:
+		return lipgloss.Color("#6E5A00")
+	}
+}
+
 func midiNoteToName(note int) string {
-	notes := []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
 	octave := (note / 12) - 1
-	noteName := notes[note%12]
+	noteName := noteNames[note%12]
 	return fmt.Sprintf("%s%d", noteName, octave)
 }
@@ -3,7 +3,10 @@ package tui
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"gitlab.com/gomidi/midi/v2/smf"
 )
 
 func TestMIDILoadingSavingWithPerStepNotes(t *testing.T) {
@@ -89,3 +92,118 @@ func TestMIDILoadingSavingWithPerStepNotes(t *testing.T) {
 
 	fmt.Println("✓ MIDI loading with per-step notes works correctly!")
 }
+
+func TestMIDIVelocityRoundTrip(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "velocity.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	if got := s.velocities[0][0]; got != defaultVelocity {
+		t.Errorf("Expected new steps to default to velocity %d, got %d", defaultVelocity, got)
+	}
+
+	s.steps[0][0] = true
+	s.velocities[0][0] = 30
+	s.steps[2][7] = true
+	s.velocities[2][7] = 127
+
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+
+	if got := s2.velocities[0][0]; got != 30 {
+		t.Errorf("Expected velocity[0][0] = 30, got %d", got)
+	}
+	if got := s2.velocities[2][7]; got != 127 {
+		t.Errorf("Expected velocity[2][7] = 127, got %d", got)
+	}
+}
+
+func TestMIDIGateAndTieRoundTrip(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "gates.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	// A short staccato note
+	s.steps[0][0] = true
+	s.gates[0][0] = 25
+
+	// A note tied across steps 4-6, released halfway through step 6
+	for step := 4; step <= 6; step++ {
+		s.steps[0][step] = true
+		s.notes[0][step] = 67
+	}
+	s.ties[0][4] = true
+	s.ties[0][5] = true
+	s.gates[0][6] = 50
+
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+
+	if got := s2.gate(0, 0); got != 25 {
+		t.Errorf("Expected gate[0][0] = 25, got %d", got)
+	}
+	if s2.ties[0][0] {
+		t.Error("Expected step 0 not to be tied")
+	}
+	for step := 4; step <= 6; step++ {
+		if !s2.steps[0][step] || s2.notes[0][step] != 67 {
+			t.Errorf("Expected step %d to hold note 67, got active=%v note=%d", step, s2.steps[0][step], s2.notes[0][step])
+		}
+	}
+	if !s2.tiedToNext(0, 4) || !s2.tiedToNext(0, 5) || s2.tiedToNext(0, 6) {
+		t.Errorf("Expected ties on steps 4 and 5 only, got %v", s2.ties[0][4:8])
+	}
+	if got := s2.gate(0, 6); got != 50 {
+		t.Errorf("Expected gate[0][6] = 50, got %d", got)
+	}
+}
+
+func TestImportedTempoIsClamped(t *testing.T) {
+	for _, tt := range []struct {
+		bpm  float64
+		want int
+	}{
+		{0.5, minBPM},
+		{1000, maxBPM},
+		{127.6, 128},
+	} {
+		testPath := filepath.Join(t.TempDir(), "tempo.mid")
+		sm := smf.New()
+		sm.TimeFormat = smf.MetricTicks(ticksPerQuarterNote)
+		var track0 smf.Track
+		track0.Add(0, smf.MetaTempo(tt.bpm))
+		track0.Close(0)
+		if err := sm.Add(track0); err != nil {
+			t.Fatal(err)
+		}
+		if err := sm.WriteFile(testPath); err != nil {
+			t.Fatalf("Error writing MIDI: %v", err)
+		}
+
+		s := &sequencerModel{}
+		if err := s.loadMIDI(testPath); err != nil {
+			t.Fatalf("Error loading MIDI: %v", err)
+		}
+		if s.bpm != tt.want {
+			t.Errorf("Expected a tempo of %v BPM to load as %d, got %d", tt.bpm, tt.want, s.bpm)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/drivers"
+)
+
+// pulsesPerStep is how many 24 PPQN clock pulses make one 16th note step.
+const pulsesPerStep = 6
+
+// tempoWindow is how many pulse intervals the derived tempo averages over.
+const tempoWindow = 24
+
+// syncColor marks the playhead and title while following an external clock.
+var syncColor = lipgloss.Color("#FF8C00")
+
+var syncStyle = lipgloss.NewStyle().
+	Bold(true).
+	Foreground(lipgloss.Color("#000000")).
+	Background(syncColor).
+	Padding(0, 1)
+
+// transportKind is a transport message received from the clock master.
+type transportKind int
+
+const (
+	transportStart transportKind = iota
+	transportContinue
+	transportStop
+)
+
+// transportMsg tells the TUI to start or stop following the master.
+type transportMsg struct {
+	follower *clockFollower
+	kind     transportKind
+	step     int // Song position for Continue, within the bar
+	bar      int // Bar of the song for Continue
+}
+
+// clockFollower listens to a MIDI input for Start/Stop/Continue, Song
+// Position Pointer and 24 PPQN Timing Clock so the sequencer can run as a
+// clock slave. Pulses go straight to the scheduler; transport messages go
+// through the TUI, which owns the scheduler's lifecycle.
+type clockFollower struct {
+	in         drivers.In
+	stopListen func()
+
+	pulses    chan time.Time // Pulses received while the master is running
+	transport chan transportMsg
+
+	mu        sync.Mutex
+	closed    bool // Whether transport is closed, once the follower is
+	running   bool
+	songPos   int // Song position in pulses
+	lastPulse time.Time
+	intervals []time.Duration // Most recent pulse intervals, for the tempo
+}
+
+func newClockFollower(in drivers.In) (*clockFollower, error) {
+	f := &clockFollower{
+		in:        in,
+		pulses:    make(chan time.Time, 4*pulsesPerStep),
+		transport: make(chan transportMsg, 8),
+	}
+	stop, err := midi.ListenTo(in, f.receive, midi.UseTimeCode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen to %s: %w", in.String(), err)
+	}
+	f.stopListen = stop
+	return f, nil
+}
+
+func (f *clockFollower) receive(msg midi.Message, _ int32) {
+	f.handle(msg, time.Now())
+}
+
+// handle processes one message from the master received at the given time.
+func (f *clockFollower) handle(msg midi.Message, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var spp uint16
+	switch {
+	case msg.Is(midi.TimingClockMsg):
+		if !f.lastPulse.IsZero() {
+			f.intervals = append(f.intervals, now.Sub(f.lastPulse))
+			if len(f.intervals) > tempoWindow {
+				f.intervals = f.intervals[1:]
+			}
+		}
+		f.lastPulse = now
+		if f.running {
+			f.songPos++
+			select {
+			case f.pulses <- now:
+			default:
+				// The scheduler fell a whole step behind; drop rather than block the driver
+			}
+		}
+	case msg.Is(midi.StartMsg):
+		f.drainPulses()
+		f.running = true
+		f.songPos = 0
+		f.notify(transportMsg{follower: f, kind: transportStart})
+	case msg.Is(midi.ContinueMsg):
+		f.drainPulses()
+		f.running = true
+		beat := f.songPos / pulsesPerStep
+		f.notify(transportMsg{follower: f, kind: transportContinue, step: beat % numSteps, bar: beat / numSteps})
+	case msg.Is(midi.StopMsg):
+		f.running = false
+		f.notify(transportMsg{follower: f, kind: transportStop})
+	case msg.GetSPP(&spp):
+		// Song position counts 16th notes, which are six pulses each
+		f.songPos = int(spp) * pulsesPerStep
+	}
+}
+
+func (f *clockFollower) notify(msg transportMsg) {
+	if f.closed {
+		return
+	}
+	select {
+	case f.transport <- msg:
+	default:
+	}
+}
+
+func (f *clockFollower) drainPulses() {
+	for {
+		select {
+		case <-f.pulses:
+		default:
+			return
+		}
+	}
+}
+
+// tempo is the BPM derived from recent pulses, or zero before enough have
+// arrived.
+func (f *clockFollower) tempo() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.intervals) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range f.intervals {
+		total += d
+	}
+	perQuarter := total * 24 / time.Duration(len(f.intervals))
+	return float64(time.Minute) / float64(perQuarter)
+}
+
+// externalTempo formats a derived tempo for display.
+func externalTempo(bpm float64) string {
+	if bpm <= 0 {
+		return "--"
+	}
+	return fmt.Sprintf("%.1f", bpm)
+}
+
+// close stops listening, and closes transport so its waiter returns.
+func (f *clockFollower) close() error {
+	f.stopListen()
+	f.closeTransport()
+	return f.in.Close()
+}
+
+func (f *clockFollower) closeTransport() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.closed = true
+		close(f.transport)
+	}
+}
+
+// waitForTransport blocks until the master starts, continues or stops, or
+// returns nothing once the follower is closed.
+func waitForTransport(f *clockFollower) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-f.transport
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+func (s *sequencerModel) refreshMIDIIns() {
+	s.midiIns = nil
+	s.midiInNames = nil
+	for _, in := range midi.GetInPorts() {
+		s.midiIns = append(s.midiIns, in)
+		s.midiInNames = append(s.midiInNames, in.String())
+	}
+}
+
+// selectSync follows the clock on the given input port, or the internal
+// clock for index -1.
+func (s *sequencerModel) selectSync(index int) (tea.Cmd, error) {
+	if index >= len(s.midiIns) {
+		return nil, fmt.Errorf("invalid port index")
+	}
+
+	if s.isPlaying {
+		s.isPlaying = false
+		s.stopPlayback()
+	}
+	s.closeSync()
+
+	if index < 0 {
+		s.message = "Using internal clock"
+		return nil, nil
+	}
+
+	f, err := newClockFollower(s.midiIns[index])
+	if err != nil {
+		return nil, err
+	}
+	s.follower = f
+	s.message = fmt.Sprintf("Following clock from: %s", s.midiInNames[index])
+	return waitForTransport(f), nil
+}
+
+// closeSync stops following an external clock.
+func (s *sequencerModel) closeSync() {
+	if s.follower != nil {
+		if err := s.follower.close(); err != nil {
+			s.message = fmt.Sprintf("Error closing sync input: %v", err)
+		}
+		s.follower = nil
+	}
+}
+
+// handleTransport starts or stops playback when the master does.
+func (s *sequencerModel) handleTransport(msg transportMsg) tea.Cmd {
+	var cmd tea.Cmd
+	switch msg.kind {
+	case transportStart:
+		cmd = s.startPlayback()
+	case transportContinue:
+		if bars := len(s.timeline()); s.songMode {
+			s.songPos = msg.bar % bars
+		}
+		cmd = s.startPlaybackAt(msg.step)
+	case transportStop:
+		s.isPlaying = false
+		s.stopPlayback()
+	}
+	return tea.Batch(cmd, waitForTransport(msg.follower))
+}
+
+// updateSyncSelection handles keys in the clock source list. Entry 0 is the
+// internal clock, followed by the input ports.
+func (s *sequencerModel) updateSyncSelection(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case keyUp, "k":
+		if s.syncCursor > 0 {
+			s.syncCursor--
+		}
+	case keyDown, "j":
+		if s.syncCursor < len(s.midiIns) {
+			s.syncCursor++
+		}
+	case "enter":
+		s.selectingSync = false
+		cmd, err := s.selectSync(s.syncCursor - 1)
+		if err != nil {
+			s.message = fmt.Sprintf("Error: %v", err)
+		}
+		return cmd
+	case "esc", "q", "i":
+		s.selectingSync = false
+	case "r":
+		s.refreshMIDIIns()
+		s.message = fmt.Sprintf("Found %d MIDI input(s)", len(s.midiIns))
+	}
+	return nil
+}
+
+func (m model) viewSyncSelection() string {
+	s := m.sequencer
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Select Clock Source") + "\n\n")
+
+	entries := append([]string{"Internal clock"}, s.midiInNames...)
+	for i, name := range entries {
+		cursor := "  "
+		if i == s.syncCursor {
+			cursor = "> "
+		}
+
+		// Mark the source currently in use
+		active := ""
+		if (i == 0 && s.follower == nil) || (i > 0 && s.follower != nil && s.follower.in.String() == name) {
+			active = " (active)"
+		}
+
+		if i == s.syncCursor {
+			b.WriteString(selectedStyle.Render(fmt.Sprintf("%s%s%s\n", cursor, name, active)))
+		} else {
+			b.WriteString(fmt.Sprintf("%s%s%s\n", cursor, name, active))
+		}
+	}
+	if len(s.midiInNames) == 0 {
+		b.WriteString("\nNo MIDI input ports found.\n")
+	}
+
+	b.WriteString("\n")
+	if s.message != "" {
+		b.WriteString(errorStyle.Render(s.message) + "\n")
+	}
+
+	b.WriteString("\n" + helpStyle.Render("↑/k: up • ↓/j: down • enter: select • r: refresh • q/esc: cancel"))
+
+	return b.String()
+}
@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gitlab.com/gomidi/midi/v2"
+)
+
+func newTestFollower() *clockFollower {
+	return &clockFollower{
+		pulses:    make(chan time.Time, 4*pulsesPerStep),
+		transport: make(chan transportMsg, 8),
+	}
+}
+
+func TestClockFollowerDerivesTempo(t *testing.T) {
+	f := newTestFollower()
+
+	// 24 pulses per quarter note at 125 BPM is a pulse every 20ms
+	now := time.Now()
+	for i := 0; i < 30; i++ {
+		f.handle(midi.TimingClock(), now.Add(time.Duration(i)*20*time.Millisecond))
+	}
+
+	if got := f.tempo(); math.Abs(got-125) > 0.01 {
+		t.Errorf("Expected tempo 125, got %f", got)
+	}
+}
+
+func TestClockFollowerTransport(t *testing.T) {
+	f := newTestFollower()
+	now := time.Now()
+
+	f.handle(midi.Start(), now)
+	if msg := <-f.transport; msg.kind != transportStart {
+		t.Errorf("Expected Start, got %v", msg.kind)
+	}
+
+	// Three steps of pulses, then stop and continue from there
+	for i := 0; i < 3*pulsesPerStep; i++ {
+		f.handle(midi.TimingClock(), now)
+	}
+	f.handle(midi.Stop(), now)
+	if msg := <-f.transport; msg.kind != transportStop {
+		t.Errorf("Expected Stop, got %v", msg.kind)
+	}
+	f.handle(midi.Continue(), now)
+	if msg := <-f.transport; msg.kind != transportContinue || msg.step != 3 {
+		t.Errorf("Expected Continue at step 3, got %v at step %d", msg.kind, msg.step)
+	}
+
+	// A Song Position Pointer relocates the next Continue
+	f.handle(midi.Stop(), now)
+	<-f.transport
+	f.handle(midi.SPP(9), now)
+	f.handle(midi.Continue(), now)
+	if msg := <-f.transport; msg.step != 9 {
+		t.Errorf("Expected Continue at step 9, got step %d", msg.step)
+	}
+}
+
+func TestClosedFollowerReleasesWaiter(t *testing.T) {
+	f := newTestFollower()
+	done := make(chan tea.Msg)
+	go func() { done <- waitForTransport(f)() }()
+
+	f.closeTransport()
+	select {
+	case msg := <-done:
+		if msg != nil {
+			t.Errorf("Expected nothing from a closed follower, got %v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the waiter to return once the follower closed")
+	}
+
+	// A message arriving late is dropped rather than sent on the closed channel
+	f.handle(midi.Stop(), time.Now())
+}
+
+func TestSchedulerFollowsPulses(t *testing.T) {
+	s := &sequencerModel{bpm: 120}
+	s.steps[0][1] = true
+	s.notes[0][1] = 62
+
+	notes := make(chan uint8, 16)
+	s.sendFunc = func(msg midi.Message) error {
+		var key, vel uint8
+		if msg.GetNoteOn(nil, &key, &vel) && vel > 0 {
+			notes <- key
+		}
+		return nil
+	}
+
+	f := newTestFollower()
+	s.follower = f
+	f.handle(midi.Start(), time.Now())
+	s.startPlayback()
+	defer s.stopPlayer()
+
+	// Nothing plays on step 1 until six pulses have moved the playhead there
+	for i := 0; i < pulsesPerStep; i++ {
+		f.handle(midi.TimingClock(), time.Now())
+	}
+	select {
+	case key := <-notes:
+		t.Fatalf("Expected no note before step 1, got %d", key)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	f.handle(midi.TimingClock(), time.Now())
+	select {
+	case key := <-notes:
+		if key != 62 {
+			t.Errorf("Expected note 62 on step 1, got %d", key)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for step 1")
+	}
+}
@@ -0,0 +1,198 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fileTemplate is what a new file starts from.
+type fileTemplate int
+
+const (
+	templateEmpty fileTemplate = iota
+	templateFourOnTheFloor
+	templateCopy
+	numTemplates
+)
+
+const defaultFileName = "new_sequence"
+
+// drumNotes are General MIDI kick, snare, closed hi-hat and open hi-hat.
+var drumNotes = [numChannels]int{36, 38, 42, 46}
+
+// newFilePrompt asks for the name and template of a file created from the
+// file browser.
+type newFilePrompt struct {
+	textPrompt
+	template fileTemplate
+	source   string // MIDI file under the cursor when the prompt opened, for templateCopy
+}
+
+// label describes a template in the new-file prompt.
+func (p *newFilePrompt) label(t fileTemplate) string {
+	switch t {
+	case templateFourOnTheFloor:
+		return "Four on the floor (kick, snare, hi-hats)"
+	case templateCopy:
+		return "Copy of " + filepath.Base(p.source)
+	default:
+		return "Empty pattern"
+	}
+}
+
+// nextTemplate cycles the template, skipping the copy when there is nothing
+// to copy.
+func (p *newFilePrompt) nextTemplate() {
+	p.template = (p.template + 1) % numTemplates
+	if p.template == templateCopy && p.source == "" {
+		p.template = templateEmpty
+	}
+}
+
+// nextFreeName returns the first of new_sequence.mid, new_sequence_2.mid,
+// ... that doesn't exist yet in dir.
+func nextFreeName(dir string) string {
+	name := defaultFileName + ".mid"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, name)); errors.Is(err, fs.ErrNotExist) {
+			return name
+		}
+		name = fmt.Sprintf("%s_%d.mid", defaultFileName, i)
+	}
+}
+
+// openNewFile starts the new-file prompt with a free default name. A MIDI
+// file under the cursor can be copied.
+func (fb *fileBrowserModel) openNewFile() {
+	p := &newFilePrompt{textPrompt: textPrompt{title: "New MIDI File", value: nextFreeName(fb.currentDir)}}
+	if len(fb.files) > 0 && !fb.files[fb.cursor].isDir {
+		p.source = fb.files[fb.cursor].path
+	}
+	fb.newFile = p
+	fb.message = ""
+}
+
+// updateNewFile handles keys in the new-file prompt. Existing files are never
+// overwritten; the prompt stays open until a free name is given.
+func (m model) updateNewFile(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	fb := &m.fileBrowser
+	p := fb.newFile
+
+	if msg.Type == tea.KeyTab {
+		p.nextTemplate()
+		return m, nil
+	}
+	submitted, cancelled := p.update(msg)
+	if cancelled {
+		fb.newFile = nil
+		return m, nil
+	}
+	if !submitted {
+		return m, nil
+	}
+
+	path, err := midiFilePath(fb.currentDir, p.value)
+	if err != nil {
+		p.err = err.Error()
+		return m, nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		p.err = fmt.Sprintf("%s already exists: choose another name", filepath.Base(path))
+		return m, nil
+	}
+
+	if err := m.sequencer.createFromTemplate(path, p.template, p.source); err != nil {
+		p.err = fmt.Sprintf("Error creating MIDI: %v", err)
+		return m, nil
+	}
+	fb.newFile = nil
+	m.mode = sequencerMode
+	return m, nil
+}
+
+func (m model) viewNewFile() string {
+	p := m.fileBrowser.newFile
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(p.title) + "\n\n")
+	fmt.Fprintf(&b, "Directory: %s\n\n", m.fileBrowser.currentDir)
+	b.WriteString(selectedStyle.Render("> "+p.value) + "█\n\n")
+	b.WriteString("Template:\n")
+	for t := range numTemplates {
+		if t == templateCopy && p.source == "" {
+			continue
+		}
+		line := "  " + p.label(t)
+		if t == p.template {
+			line = selectedStyle.Render("> " + p.label(t))
+		}
+		b.WriteString(line + "\n")
+	}
+	if p.err != "" {
+		b.WriteString("\n" + errorStyle.Render(p.err) + "\n")
+	}
+	b.WriteString("\n" + helpStyle.Render("enter: create • tab: next template • esc: cancel"))
+
+	return b.String()
+}
+
+// createFromTemplate creates a new file at path from a template. Copies keep
+// everything in the source file, including its pattern bank and song.
+func (s *sequencerModel) createFromTemplate(path string, t fileTemplate, source string) error {
+	if t == templateCopy {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+		// A recovery file left over from a deleted file of the same name is stale
+		s.discardRecovery(path)
+		delete(s.histories, path)
+		if err := s.loadMIDI(path); err != nil {
+			return err
+		}
+		s.message = fmt.Sprintf("Copied %s to %s", filepath.Base(source), filepath.Base(path))
+		return nil
+	}
+
+	if err := s.createNewMIDI(path); err != nil {
+		return err
+	}
+	if t == templateFourOnTheFloor {
+		s.pattern = fourOnTheFloor()
+		if err := s.save(); err != nil {
+			return err
+		}
+		s.message = "New MIDI file created from the four-on-the-floor template"
+	}
+	return nil
+}
+
+// fourOnTheFloor is a basic drum groove: kick on every beat, snare on two and
+// four, closed hi-hats on the off-beats and an open hi-hat at the end of the
+// bar.
+func fourOnTheFloor() pattern {
+	p := newPattern()
+	for ch := range numChannels {
+		for step := range numSteps {
+			p.notes[ch][step] = drumNotes[ch]
+		}
+	}
+	for step := 0; step < numSteps; step += 4 {
+		p.steps[0][step] = true
+		p.steps[2][step+2] = true
+	}
+	p.steps[1][4] = true
+	p.steps[1][12] = true
+	p.steps[2][14] = false
+	p.steps[3][14] = true
+	return p
+}
@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// browserIn returns a file browser model listing dir.
+func browserIn(dir string) model {
+	m := InitialModel()
+	m.fileBrowser.currentDir = dir
+	m.fileBrowser.loadFiles()
+	return m
+}
+
+// typeKeys sends key presses to the model, typing text as runes.
+func typeKeys(m model, msgs ...tea.KeyMsg) model {
+	for _, msg := range msgs {
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestNextFreeName(t *testing.T) {
+	dir := t.TempDir()
+	if got := nextFreeName(dir); got != "new_sequence.mid" {
+		t.Errorf("Expected new_sequence.mid, got %s", got)
+	}
+	for _, name := range []string{"new_sequence.mid", "new_sequence_2.mid"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := nextFreeName(dir); got != "new_sequence_3.mid" {
+		t.Errorf("Expected new_sequence_3.mid, got %s", got)
+	}
+}
+
+func TestNewFileRefusesExistingName(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "taken.mid")
+	if err := os.WriteFile(existing, []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := browserIn(dir)
+	m = typeKeys(m, runes("n"))
+	if m.fileBrowser.newFile == nil {
+		t.Fatal("Expected n to open the new file prompt")
+	}
+	if got := m.fileBrowser.newFile.value; got != "new_sequence.mid" {
+		t.Errorf("Expected the default name new_sequence.mid, got %s", got)
+	}
+
+	m.fileBrowser.newFile.value = ""
+	m = typeKeys(m, runes("t"), runes("a"), runes("k"), runes("e"), runes("n"), tea.KeyMsg{Type: tea.KeyEnter})
+	if m.mode != fileBrowserMode || m.fileBrowser.newFile.err == "" {
+		t.Fatal("Expected an existing name to be refused")
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "keep" {
+		t.Error("Expected the existing file to be left alone")
+	}
+
+	// q is part of the name, not a request to quit
+	m = typeKeys(m, tea.KeyMsg{Type: tea.KeyBackspace}, runes("q"), tea.KeyMsg{Type: tea.KeyEnter})
+	if m.mode != sequencerMode || m.sequencer.filePath != filepath.Join(dir, "takeq.mid") {
+		t.Fatalf("Expected takeq.mid to open, got %s", m.sequencer.filePath)
+	}
+}
+
+func TestNewFileTemplates(t *testing.T) {
+	dir := t.TempDir()
+
+	m := browserIn(dir)
+	m = typeKeys(m, runes("n"), tea.KeyMsg{Type: tea.KeyTab}, tea.KeyMsg{Type: tea.KeyEnter})
+	s := &m.sequencer
+	if !s.steps[0][0] || !s.steps[0][12] || !s.steps[1][4] || s.notes[0][0] != 36 {
+		t.Fatal("Expected a four-on-the-floor kick and snare")
+	}
+	if s.dirty {
+		t.Error("Expected the template to be saved")
+	}
+	s.steps[3][1] = true
+	if err := s.save(); err != nil {
+		t.Fatalf("Error saving: %v", err)
+	}
+
+	// Copy the file under the cursor
+	m = m.leaveSequencer()
+	for i, f := range m.fileBrowser.files {
+		if f.name == "new_sequence.mid" {
+			m.fileBrowser.cursor = i
+		}
+	}
+	m = typeKeys(m, runes("n"))
+	if got := m.fileBrowser.newFile.value; got != "new_sequence_2.mid" {
+		t.Errorf("Expected the default name to count up, got %s", got)
+	}
+	m = typeKeys(m, tea.KeyMsg{Type: tea.KeyTab}, tea.KeyMsg{Type: tea.KeyTab}, tea.KeyMsg{Type: tea.KeyEnter})
+	s = &m.sequencer
+	if s.filePath != filepath.Join(dir, "new_sequence_2.mid") || !s.steps[3][1] || !s.steps[0][0] {
+		t.Error("Expected a copy of new_sequence.mid")
+	}
+}
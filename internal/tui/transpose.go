@@ -0,0 +1,235 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// transposeScope is what a transposition applies to.
+type transposeScope int
+
+const (
+	scopeSelection transposeScope = iota
+	scopeChannel
+	scopePattern
+	numScopes
+)
+
+// transposeUnit is what a transposition counts in.
+type transposeUnit int
+
+const (
+	unitSemitones transposeUnit = iota
+	unitOctaves
+	unitDegrees
+	numUnits
+)
+
+// transposeField is the setting highlighted in the transpose overlay.
+type transposeField int
+
+const (
+	fieldScope transposeField = iota
+	fieldUnit
+	fieldAmount
+	numTransposeFields
+)
+
+// maxTranspose is the furthest a transposition goes in any unit.
+const maxTranspose = 24
+
+// transposer is the transposition being set up in the overlay.
+type transposer struct {
+	scope  transposeScope
+	unit   transposeUnit
+	amount int
+	field  transposeField
+}
+
+// shift moves one note by the transposition, clamped to the MIDI range.
+// Scale degrees follow the scale lock, or are semitones without one.
+func (t *transposer) shift(k *scaleLock, note int) int {
+	switch t.unit {
+	case unitOctaves:
+		note += 12 * t.amount
+	case unitDegrees:
+		for range abs(t.amount) {
+			note = k.step(note, sign(t.amount))
+		}
+	default:
+		note += t.amount
+	}
+	return min(max(note, 0), maxMIDINote)
+}
+
+// transposeBounds returns the steps and channels in scope.
+func (s *sequencerModel) transposeBounds(scope transposeScope) (x0, y0, x1, y1 int) {
+	switch scope {
+	case scopeSelection:
+		return s.selection()
+	case scopeChannel:
+		return 0, s.cursorY, numSteps - 1, s.cursorY
+	default:
+		return 0, 0, numSteps - 1, numChannels - 1
+	}
+}
+
+// transposeStep moves a step's root and chord notes. Chord notes moved by
+// scale degree can change their intervals, and notes pushed to the same
+// pitch by the clamp merge.
+func (s *sequencerModel) transposeStep(t *transposer, ch, step int) {
+	old := s.notes[ch][step]
+	root := t.shift(&s.key, old)
+	var intervals []int
+	for _, interval := range s.chords[ch][step] {
+		if i := t.shift(&s.key, old+interval) - root; i > 0 && !slices.Contains(intervals, i) {
+			intervals = append(intervals, i)
+		}
+	}
+	slices.Sort(intervals)
+	s.notes[ch][step] = root
+	s.chords[ch][step] = intervals
+}
+
+// transpose applies the transposition to every step in scope, playing or
+// not, so steps switched on later are in the new key too.
+func (s *sequencerModel) transpose(t *transposer) {
+	x0, y0, x1, y1 := s.transposeBounds(t.scope)
+	for ch := y0; ch <= y1; ch++ {
+		for step := x0; step <= x1; step++ {
+			s.transposeStep(t, ch, step)
+		}
+	}
+	s.selecting = false
+	s.message = fmt.Sprintf("Transposed %s by %s", t.scopeName(), t.amountName())
+}
+
+// openTranspose starts the overlay on the selection if there is one, or
+// else on the cursor's channel.
+func (s *sequencerModel) openTranspose() {
+	t := &transposer{scope: scopeChannel}
+	if s.selecting {
+		t.scope = scopeSelection
+	}
+	if s.key.locked() {
+		t.unit = unitDegrees
+	}
+	s.transposing = t
+}
+
+func (t *transposer) scopeName() string {
+	switch t.scope {
+	case scopeSelection:
+		return "the selection"
+	case scopeChannel:
+		return "the channel"
+	default:
+		return "the pattern"
+	}
+}
+
+func (t *transposer) unitName(k *scaleLock) string {
+	switch t.unit {
+	case unitOctaves:
+		return "octaves"
+	case unitDegrees:
+		if k.locked() {
+			return "scale degrees (" + k.name() + ")"
+		}
+		return "scale degrees (no scale: semitones)"
+	default:
+		return "semitones"
+	}
+}
+
+// amountName describes the amount with its unit, e.g. "+2 octave(s)".
+func (t *transposer) amountName() string {
+	unit := "semitone(s)"
+	switch t.unit {
+	case unitOctaves:
+		unit = "octave(s)"
+	case unitDegrees:
+		unit = "scale degree(s)"
+	}
+	return fmt.Sprintf("%+d %s", t.amount, unit)
+}
+
+// updateTranspose handles keys in the transpose overlay.
+func (s *sequencerModel) updateTranspose(msg tea.KeyMsg) {
+	t := s.transposing
+	switch msg.String() {
+	case keyLeft, "h":
+		t.field = (t.field + numTransposeFields - 1) % numTransposeFields
+	case keyRight, "l", "tab":
+		t.field = (t.field + 1) % numTransposeFields
+	case keyUp, "k", keyDown, "j":
+		delta := 1
+		if key := msg.String(); key == keyDown || key == "j" {
+			delta = -1
+		}
+		switch t.field {
+		case fieldScope:
+			t.scope = (t.scope + numScopes + transposeScope(delta)) % numScopes
+			if t.scope == scopeSelection && !s.selecting {
+				// Skip the selection when nothing is selected
+				t.scope = (t.scope + numScopes + transposeScope(delta)) % numScopes
+			}
+		case fieldUnit:
+			t.unit = (t.unit + numUnits + transposeUnit(delta)) % numUnits
+		case fieldAmount:
+			t.amount = min(max(t.amount+delta, -maxTranspose), maxTranspose)
+		}
+	case "enter":
+		if t.amount != 0 {
+			s.transpose(t)
+		}
+		s.transposing = nil
+	case "esc", "q", "T":
+		s.transposing = nil
+	}
+}
+
+func (m model) viewTranspose() string {
+	s := m.sequencer
+	t := s.transposing
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Transpose") + "\n\n")
+
+	values := [numTransposeFields]string{
+		"Apply to: " + t.scopeName(),
+		"Unit: " + t.unitName(&s.key),
+		fmt.Sprintf("Amount: %+d", t.amount),
+	}
+	for field, value := range values {
+		if transposeField(field) == t.field {
+			b.WriteString(selectedStyle.Render("> "+value) + "\n")
+		} else {
+			b.WriteString("  " + value + "\n")
+		}
+	}
+
+	// Preview the notes in scope on the cursor's channel, or the first one
+	x0, y0, x1, y1 := s.transposeBounds(t.scope)
+	ch := min(max(s.cursorY, y0), y1)
+	var before, after []string
+	for step := x0; step <= x1; step++ {
+		if s.steps[ch][step] && !s.isTieContinuation(ch, step) {
+			before = append(before, midiNoteToName(s.notes[ch][step]))
+			after = append(after, midiNoteToName(t.shift(&s.key, s.notes[ch][step])))
+		}
+	}
+	if len(before) > 0 {
+		fmt.Fprintf(&b, "\nCh %d now:   %s\n", ch+1, strings.Join(before, " "))
+		fmt.Fprintf(&b, "Ch %d after: %s\n", ch+1, strings.Join(after, " "))
+	}
+	b.WriteString("\nNotes stop at C-1 and G9, the ends of the MIDI range.\n")
+
+	b.WriteString("\n" + helpStyle.Render("↑/↓: change • ←/→: choose setting • enter: transpose • esc: cancel"))
+
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTranspose(t *testing.T) {
+	cMajor := scaleLock{scale: scaleMajor}
+	for _, tt := range []struct {
+		tr   transposer
+		note int
+		want int
+	}{
+		{transposer{unit: unitSemitones, amount: 3}, 60, 63},
+		{transposer{unit: unitOctaves, amount: -2}, 60, 36},
+		{transposer{unit: unitDegrees, amount: 2}, 60, 64},   // C to E
+		{transposer{unit: unitDegrees, amount: -1}, 60, 59},  // C to B
+		{transposer{unit: unitOctaves, amount: 2}, 120, 127}, // Clamped to the top
+		{transposer{unit: unitSemitones, amount: -5}, 2, 0},  // Clamped to the bottom
+	} {
+		if got := tt.tr.shift(&cMajor, tt.note); got != tt.want {
+			t.Errorf("Expected %s %s to give %s, got %s", midiNoteToName(tt.note), tt.tr.amountName(), midiNoteToName(tt.want), midiNoteToName(got))
+		}
+	}
+}
+
+func TestTransposeKeys(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "transpose.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s := &m.sequencer
+	s.key = scaleLock{scale: scaleMajor}
+	s.steps[0][0] = true
+	s.chords[0][0] = []int{4, 7} // C major
+	s.steps[1][0] = true
+
+	// Up a scale degree on channel 1 only: C major becomes D minor
+	m = pressKeys(m, "T", "l", "l", "k", "enter")
+	s = &m.sequencer
+	if s.notes[0][0] != 62 || !slices.Equal(s.chords[0][0], []int{3, 7}) {
+		t.Errorf("Expected D minor, got %s", s.chordName(0, 0))
+	}
+	if s.notes[1][0] != defaultNotes[1] {
+		t.Error("Expected the other channels to stay put")
+	}
+
+	// Down an octave over the whole pattern
+	m = pressKeys(m, "T", "k", "l", "j", "l", "j", "enter")
+	s = &m.sequencer
+	if s.notes[0][0] != 50 || s.notes[1][0] != defaultNotes[1]-12 || s.notes[3][15] != defaultNotes[3]-12 {
+		t.Errorf("Expected the whole pattern an octave down, got %s and %s", midiNoteToName(s.notes[0][0]), midiNoteToName(s.notes[1][0]))
+	}
+
+	// A selection transposes only the selected steps
+	m.sequencer.cursorX, m.sequencer.cursorY = 2, 2
+	m = pressKeys(m, "v", "l", "T", "l", "l", "k", "enter")
+	s = &m.sequencer
+	if s.notes[2][1] != defaultNotes[2]-12 || s.notes[2][4] != defaultNotes[2]-12 || s.notes[2][2] == defaultNotes[2]-12 {
+		t.Error("Expected only steps 2-3 to move")
+	}
+}
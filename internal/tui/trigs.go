@@ -0,0 +1,245 @@
+package tui
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	minChance       = 10  // Least likely a step can be to play, as a percentage
+	maxChance       = 100 // Always plays
+	chanceIncrement = 10  // Amount 9/0 change a step's chance by
+	maxRatchet      = 8   // Most repeats a step can be split into
+	maxExportBars   = 512 // Longest variation export
+)
+
+// trigCondition makes a step play only on one pass out of every few: the
+// nth of every every passes through the pattern. The zero value plays on
+// every pass.
+type trigCondition struct {
+	nth   int
+	every int
+}
+
+// trigConditions are the conditions n cycles through, always first.
+var trigConditions = func() []trigCondition {
+	conditions := []trigCondition{{}}
+	for _, every := range []int{2, 3, 4, 8} {
+		for nth := 1; nth <= every; nth++ {
+			conditions = append(conditions, trigCondition{nth: nth, every: every})
+		}
+	}
+	return conditions
+}()
+
+// matches reports whether the condition lets a step play on the given pass,
+// counting from zero.
+func (c trigCondition) matches(pass int) bool {
+	return c.every <= 0 || pass%c.every == c.nth-1
+}
+
+// String writes the condition as "nth:every", or nothing when it always plays.
+func (c trigCondition) String() string {
+	if c.every <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", c.nth, c.every)
+}
+
+// parseCondition reads a condition written by String. Anything else plays
+// on every pass.
+func parseCondition(s string) trigCondition {
+	nth, every, ok := strings.Cut(s, ":")
+	if !ok {
+		return trigCondition{}
+	}
+	c := trigCondition{}
+	c.nth, _ = strconv.Atoi(nth)
+	c.every, _ = strconv.Atoi(every)
+	if c.every < 1 || c.nth < 1 || c.nth > c.every {
+		return trigCondition{}
+	}
+	return c
+}
+
+// trigRule decides whether a step's notes play on a given pass. The zero
+// value always plays.
+type trigRule struct {
+	chance int // Percentage; zero or maxChance always plays
+	cond   trigCondition
+}
+
+// always reports whether the rule never skips the step.
+func (r trigRule) always() bool {
+	return (r.chance <= 0 || r.chance >= maxChance) && r.cond.every <= 0
+}
+
+// fires decides whether the step numbered trig plays on the given pass. The
+// dice roll is seeded by the pass and step, so the same seed always gives
+// the same variation, whether it is played or exported and wherever
+// playback started.
+func (r trigRule) fires(seed uint64, pass, trig int) bool {
+	if !r.cond.matches(pass) {
+		return false
+	}
+	if r.chance <= 0 || r.chance >= maxChance {
+		return true
+	}
+	rng := rand.New(rand.NewPCG(seed, uint64(pass)<<16|uint64(trig))) //nolint:gosec // musical randomness, not security
+	return rng.IntN(maxChance) < r.chance
+}
+
+// chance returns the step's chance of playing, falling back to always for
+// steps that were never given one.
+func (s *sequencerModel) chance(ch, step int) int {
+	c := s.chances[ch][step]
+	if c < minChance || c > maxChance {
+		return maxChance
+	}
+	return c
+}
+
+// ratchet returns how many repeats the step is split into, one when it
+// isn't split.
+func (s *sequencerModel) ratchet(ch, step int) int {
+	return min(max(s.ratchets[ch][step], 1), maxRatchet)
+}
+
+// trigRule returns the rule for a step's notes.
+func (s *sequencerModel) trigRule(ch, step int) trigRule {
+	return trigRule{chance: s.chance(ch, step), cond: s.conditions[ch][step]}
+}
+
+// trigID numbers a step across all channels from one, so every step that
+// triggers notes has its own dice.
+func trigID(ch, step int) int {
+	return ch*numSteps + step + 1
+}
+
+// changeChance moves the cursor step's chance of playing.
+func (s *sequencerModel) changeChance(delta int) {
+	c := &s.chances[s.cursorY][s.cursorX]
+	*c = min(max(s.chance(s.cursorY, s.cursorX)+delta, minChance), maxChance)
+	s.message = fmt.Sprintf("Step %X plays %d%% of the time", s.cursorX, *c)
+}
+
+// cycleCondition moves the cursor step on to the next or previous condition.
+func (s *sequencerModel) cycleCondition(dir int) {
+	c := &s.conditions[s.cursorY][s.cursorX]
+	i := 0
+	for j, cond := range trigConditions {
+		if cond == *c {
+			i = j
+		}
+	}
+	*c = trigConditions[(i+dir+len(trigConditions))%len(trigConditions)]
+	if c.every == 0 {
+		s.message = fmt.Sprintf("Step %X plays on every pass", s.cursorX)
+	} else {
+		s.message = fmt.Sprintf("Step %X plays on pass %d of every %d", s.cursorX, c.nth, c.every)
+	}
+}
+
+// cycleRatchet splits the cursor step into one more repeat, wrapping back
+// to a single note after maxRatchet.
+func (s *sequencerModel) cycleRatchet() {
+	r := &s.ratchets[s.cursorY][s.cursorX]
+	*r = s.ratchet(s.cursorY, s.cursorX)%maxRatchet + 1
+	s.message = fmt.Sprintf("Step %X ratchet x%d", s.cursorX, *r)
+}
+
+// newSeed picks a seed for the dice of probability steps.
+func newSeed() uint64 {
+	return rand.Uint64() //nolint:gosec // musical randomness, not security
+}
+
+// rerollSeed picks a new seed, giving probability steps a new variation.
+func (s *sequencerModel) rerollSeed() {
+	s.seed = newSeed()
+	s.message = fmt.Sprintf("New variation (seed %d)", s.seed)
+}
+
+// trigLabel describes a step's probability, condition and ratchet for the
+// status line, or nothing if it plays plainly.
+func (s *sequencerModel) trigLabel(ch, step int) string {
+	var parts []string
+	if c := s.chance(ch, step); c < maxChance {
+		parts = append(parts, fmt.Sprintf("chance %d%%", c))
+	}
+	if c := s.conditions[ch][step]; c.every > 0 {
+		parts = append(parts, "pass "+c.String())
+	}
+	if r := s.ratchet(ch, step); r > 1 {
+		parts = append(parts, fmt.Sprintf("ratchet x%d", r))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, " ")
+}
+
+// variationEvents renders one channel over a number of bars, following the
+// timeline and deciding each probability and condition the way playback
+// would.
+func (s *sequencerModel) variationEvents(order []int, bars, ch int) []noteEvent {
+	rendered := make(map[int][]noteEvent)
+	var events []noteEvent
+	for bar := range bars {
+		slot := order[bar%len(order)]
+		barEvents, ok := rendered[slot]
+		if !ok {
+			barEvents = s.withPattern(slot).channelEvents(ch)
+			rendered[slot] = barEvents
+		}
+		for _, ev := range barEvents {
+			if ev.trig != 0 && !ev.rule.fires(s.seed, bar, ev.trig) {
+				continue
+			}
+			ev.tick += uint32(bar) * patternTicks //nolint:gosec // bar is bounded by maxExportBars
+			events = append(events, ev)
+		}
+	}
+	sortEvents(events)
+	return events
+}
+
+// openExportBars asks how many bars of variation saving should render.
+func (s *sequencerModel) openExportBars() {
+	s.exportPrompt = &textPrompt{title: "Export Bars", value: strconv.Itoa(s.exportBars)}
+}
+
+// updateExportBars handles keys in the export bars prompt.
+func (s *sequencerModel) updateExportBars(msg tea.KeyMsg) {
+	p := s.exportPrompt
+	submitted, cancelled := p.update(msg)
+	if cancelled {
+		s.exportPrompt = nil
+		return
+	}
+	if !submitted {
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(p.value))
+	if err != nil || n < 0 || n > maxExportBars {
+		p.err = fmt.Sprintf("enter a number of bars from 0 to %d", maxExportBars)
+		return
+	}
+	s.exportPrompt = nil
+	s.exportBars = n
+	if n == 0 {
+		s.message = "Saving the pattern as written, every step included"
+	} else {
+		s.message = fmt.Sprintf("Saving %d bars with probabilities and conditions played out", n)
+	}
+}
+
+func (m model) viewExportBars() string {
+	var b strings.Builder
+	b.WriteString(m.sequencer.exportPrompt.view())
+	b.WriteString("\n\n" + helpStyle.Render("0 saves the pattern or song once as written; more bars render the variation playback would give"))
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+func TestTrigConditions(t *testing.T) {
+	c := trigCondition{nth: 2, every: 4}
+	var passes []int
+	for pass := range 10 {
+		if c.matches(pass) {
+			passes = append(passes, pass)
+		}
+	}
+	if want := []int{1, 5, 9}; !slices.Equal(passes, want) {
+		t.Errorf("Expected 2:4 to play on passes %v, got %v", want, passes)
+	}
+
+	for _, cond := range trigConditions {
+		if got := parseCondition(cond.String()); got != cond {
+			t.Errorf("Expected %q to parse back, got %v", cond.String(), got)
+		}
+	}
+	for _, bad := range []string{"3:2", "0:4", "x:y", "1:0"} {
+		if got := parseCondition(bad); got != (trigCondition{}) {
+			t.Errorf("Expected %q to play on every pass, got %v", bad, got)
+		}
+	}
+}
+
+func TestChanceIsSeeded(t *testing.T) {
+	rule := trigRule{chance: 50}
+	played := func(seed uint64) []bool {
+		var got []bool
+		for pass := range 64 {
+			got = append(got, rule.fires(seed, pass, 1))
+		}
+		return got
+	}
+
+	first := played(1)
+	if !slices.Equal(first, played(1)) {
+		t.Error("Expected the same seed to give the same variation")
+	}
+	if slices.Equal(first, played(2)) {
+		t.Error("Expected another seed to give another variation")
+	}
+	hits := 0
+	for _, on := range first {
+		if on {
+			hits++
+		}
+	}
+	if hits < 16 || hits > 48 {
+		t.Errorf("Expected about half of 64 passes at 50%%, got %d", hits)
+	}
+}
+
+func TestRatchetSplitsStep(t *testing.T) {
+	s := &sequencerModel{}
+	s.resetBank()
+	s.steps[0][0] = true
+	s.steps[0][1] = true
+	s.ties[0][0] = true
+	s.ratchets[0][0] = 4
+
+	var ons []uint32
+	for _, ev := range s.channelEvents(0) {
+		if ev.velocity > 0 {
+			ons = append(ons, ev.tick)
+		}
+	}
+	every := uint32(ticksPerStep / 4)
+	if want := []uint32{0, every, 2 * every, 3 * every, ticksPerStep}; !slices.Equal(ons, want) {
+		t.Errorf("Expected a ratchet to break the tie and repeat at %v, got %v", want, ons)
+	}
+}
+
+func TestTrigKeys(t *testing.T) {
+	m := InitialModel()
+	m.mode = sequencerMode
+	if err := m.sequencer.createNewMIDI(filepath.Join(t.TempDir(), "trigs.mid")); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+
+	m = pressKeys(m, "9", "9", "n", "n", "r")
+	s := &m.sequencer
+	if got := s.trigLabel(0, 0); got != " chance 80% pass 2:2 ratchet x2" {
+		t.Errorf("Expected the step's chance, condition and ratchet, got %q", got)
+	}
+
+	seed := s.seed
+	m = pressKeys(m, "Z", "u")
+	if m.sequencer.seed != seed {
+		t.Error("Expected undo to bring back the old variation")
+	}
+}
+
+func TestVariationExport(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "variation.mid")
+
+	s := &sequencerModel{}
+	if err := s.createNewMIDI(testPath); err != nil {
+		t.Fatalf("Error creating MIDI: %v", err)
+	}
+	s.steps[0][0] = true
+	s.conditions[0][0] = trigCondition{nth: 2, every: 2}
+	s.steps[0][8] = true
+	s.chances[0][8] = 50
+	s.seed = 42
+	s.exportBars = 8
+	if err := s.saveMIDI(); err != nil {
+		t.Fatalf("Error saving MIDI: %v", err)
+	}
+
+	rd, err := smf.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Error reading MIDI: %v", err)
+	}
+	var want []uint32
+	for bar := range 8 {
+		at := uint32(bar) * patternTicks
+		if bar%2 == 1 {
+			want = append(want, at)
+		}
+		if s.trigRule(0, 8).fires(s.seed, bar, trigID(0, 8)) {
+			want = append(want, at+8*ticksPerStep)
+		}
+	}
+	if got := noteOnTicks(rd.Tracks[1]); !slices.Equal(got, want) {
+		t.Errorf("Expected note ons at %v, got %v", want, got)
+	}
+
+	s2 := &sequencerModel{}
+	if err := s2.loadMIDI(testPath); err != nil {
+		t.Fatalf("Error loading MIDI: %v", err)
+	}
+	if s2.seed != 42 || s2.exportBars != 8 {
+		t.Errorf("Expected seed 42 and 8 bars, got seed %d and %d bars", s2.seed, s2.exportBars)
+	}
+	if s2.conditions[0][0] != (trigCondition{nth: 2, every: 2}) || s2.chance(0, 8) != 50 || !s2.steps[0][0] {
+		t.Errorf("Expected the pattern as written to be restored, got condition %v and chance %d", s2.conditions[0][0], s2.chance(0, 8))
+	}
+}